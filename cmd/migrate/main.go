@@ -2,27 +2,37 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"os"
 	"user-management-service/internal/config"
 	"user-management-service/internal/database"
 )
 
 func main() {
-	cfg := config.LoadConfig()
-	database.ConnectDB(cfg.DatabaseURL)
-	defer database.CloseDB()
+	configFile := flag.String("config", "", "path to a YAML config file (default $CONFIG_FILE)")
+	flag.Parse()
 
-	migration, err := os.ReadFile("migrations/20260212_create_otps.sql")
+	cfg, err := config.Load(*configFile)
 	if err != nil {
-		log.Fatalf("Failed to read migration: %v", err)
+		log.Fatal(err)
+	}
+	if err := database.Connect(cfg); err != nil {
+		log.Fatal(err)
 	}
+	defer database.CloseDB()
 
-	_, err = database.DB.Exec(context.Background(), string(migration))
+	applied, err := database.Migrate(context.Background())
+	for _, version := range applied {
+		fmt.Printf("Applied %s\n", version)
+	}
 	if err != nil {
 		log.Fatalf("Failed to run migration: %v", err)
 	}
 
+	if len(applied) == 0 {
+		fmt.Println("Database schema is up to date")
+		return
+	}
 	fmt.Println("Migration applied successfully!")
 }
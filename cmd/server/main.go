@@ -1,64 +1,49 @@
 package main
 
 import (
-	"log"
-	"net/http"
-	_ "net/http/pprof"
-	"user-management-service/graph"
-	"user-management-service/internal/config"
-	"user-management-service/internal/database"
-	"user-management-service/internal/email"
-	"user-management-service/internal/middleware"
-	"user-management-service/internal/router"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
 
-	"github.com/99designs/gqlgen/graphql/handler"
-	"github.com/99designs/gqlgen/graphql/playground"
-	"github.com/rs/cors"
+	"user-management-service/internal/app"
+	"user-management-service/internal/config"
+	"user-management-service/internal/logging"
 )
 
 func main() {
-	// 1. Load Configuration
-	cfg := config.LoadConfig()
-	log.Println("Starting User Management Service...")
-
-	// 2. Initialize Email Service
-	email.Init(cfg)
-
-	// 3. Connect to Database (blocks until connected or fails)
-	database.ConnectDB(cfg.DatabaseURL)
-	defer database.CloseDB()
-
-	// 3. Setup Router
-	r := router.SetupRouter()
-
-	// GraphQL Handler
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{Config: cfg}}))
-	r.Handle("/graphql", srv)
-	r.Handle("/playground", playground.Handler("GraphQL playground", "/graphql"))
-
-	// 4. Start Server
-	log.Printf("Server listening on port %s", cfg.Port)
-
-	// Start pprof server in a goroutine
-	go func() {
-		log.Println("pprof running on http://localhost:6060/debug/pprof/")
-		if err := http.ListenAndServe("localhost:6060", nil); err != nil {
-			log.Printf("pprof server failed: %v", err)
+	configFile := flag.String("config", "", "path to a YAML config file (default $CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, secrets masked, and exit")
+	flag.Parse()
+
+	// 1. Load Configuration (invalid settings stop the service before it starts)
+	cfg, err := config.Load(*configFile)
+	if *printConfig && cfg != nil {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-	}()
-
-	// Add CORS middleware
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
-	})
-
-	handler := middleware.AuthMiddleware()(r)
-	handler = c.Handler(handler)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		return
+	}
+	logging.Setup(os.Stdout, cfg.LogLevel)
+	slog.Info("Starting User Management Service...")
+
+	// 2. Connect dependencies and build the HTTP handlers
+	application, err := app.New(cfg)
+	if err != nil {
+		slog.Error("Failed to initialize", "err", err)
+		os.Exit(1)
+	}
 
-	if err := http.ListenAndServe(":"+cfg.Port, handler); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	// 3. Serve until SIGINT/SIGTERM, then drain and shut down
+	if err := application.Run(); err != nil {
+		slog.Error("Server stopped with error", "err", err)
+		os.Exit(1)
 	}
 }
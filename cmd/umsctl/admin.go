@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"user-management-service/internal/auth"
+	"user-management-service/internal/config"
+	"user-management-service/internal/jobs"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+func runOTP(ctx context.Context, out *printer, args []string) error {
+	if len(args) == 0 || args[0] != "purge" {
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("otp purge", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 0, "only purge OTPs expired or used at least this long ago")
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	purged, err := repository.PurgeOTPs(ctx, time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+	return out.message("Purged %d OTPs", purged)
+}
+
+// runJob lists the maintenance jobs or runs one now, as the server's
+// scheduler would; it refuses while the job is running elsewhere
+func runJob(ctx context.Context, out *printer, cfg *config.Config, args []string) error {
+	scheduler, err := jobs.NewScheduler(jobs.Maintenance(cfg))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		latest, err := repository.GetLatestJobRuns(ctx)
+		if err != nil {
+			return err
+		}
+
+		type jobInfo struct {
+			Name      string         `json:"name"`
+			Schedule  string         `json:"schedule"`
+			NextRunAt time.Time      `json:"next_run_at"`
+			LastRun   *models.JobRun `json:"last_run"`
+		}
+		var infos []jobInfo
+		var rows [][]string
+		for _, job := range scheduler.Jobs() {
+			info := jobInfo{Name: job.Name, Schedule: job.Spec, NextRunAt: job.Next(time.Now()), LastRun: latest[job.Name]}
+			infos = append(infos, info)
+
+			row := []string{info.Name, info.Schedule, info.NextRunAt.Format(time.RFC3339), "-", "", ""}
+			if run := info.LastRun; run != nil {
+				row[3], row[4], row[5] = run.StartedAt.Format(time.RFC3339), run.Status, run.Error
+			}
+			rows = append(rows, row)
+		}
+		return out.print(infos, []string{"JOB", "SCHEDULE", "NEXT RUN", "LAST RUN", "STATUS", "ERROR"}, rows)
+	case len(args) == 2 && args[0] == "run":
+		run, err := scheduler.RunNow(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if run == nil {
+			return errors.New("the job has already run")
+		}
+		if run.Status == models.JobFailed {
+			return fmt.Errorf("job %s failed: %s", run.Job, run.Error)
+		}
+		return out.message("Job %s affected %d rows", run.Job, run.Affected)
+	}
+	return errUsage
+}
+
+func runSession(ctx context.Context, out *printer, args []string) error {
+	if len(args) == 0 || args[0] != "revoke" {
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("session revoke", flag.ContinueOnError)
+	userRef := fs.String("user", "", "revoke every session of this user (id or email)")
+	positional, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *userRef != "" && len(positional) == 0:
+		user, err := findUser(ctx, *userRef)
+		if err != nil {
+			return err
+		}
+		revoked, err := repository.RevokeUserSessions(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		return out.message("Revoked %d sessions for %s", revoked, user.Email)
+	case *userRef == "" && len(positional) == 1:
+		if err := repository.RevokeSession(ctx, positional[0]); err != nil {
+			return err
+		}
+		return out.message("Revoked session %s", positional[0])
+	}
+	return errUsage
+}
+
+// runToken mints a session token for a user, for local testing against the API
+func runToken(ctx context.Context, out *printer, args []string) error {
+	if len(args) != 2 || args[0] != "mint" {
+		return errUsage
+	}
+
+	user, err := findUser(ctx, args[1])
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.IssueSession(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Minted a %s session for %s (%s). Do not use in production.\n", auth.SessionTTL, user.Email, user.Role)
+	if out.format == "json" {
+		return out.print(map[string]string{"token": token}, nil, nil)
+	}
+	_, err = fmt.Fprintln(out.w, token)
+	return err
+}
+
+// runBootstrapAdmin creates the first administrator. It refuses to run once an
+// administrator exists; use "user set-role" from then on.
+func runBootstrapAdmin(ctx context.Context, out *printer, args []string) error {
+	fs := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	name := fs.String("name", "Administrator", "display name if the user is created")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errUsage
+	}
+
+	user, err := repository.BootstrapAdmin(ctx, *name, positional[0])
+	if errors.Is(err, repository.ErrAdminExists) {
+		return errors.New("an administrator already exists; ask them to grant the role with 'umsctl user set-role'")
+	}
+	if err != nil {
+		return err
+	}
+	return out.users(user)
+}
@@ -0,0 +1,186 @@
+// Command umsctl administers the user management service from the command
+// line, using the same repository layer as the server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"user-management-service/internal/auth"
+	"user-management-service/internal/config"
+	"user-management-service/internal/database"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+const usageText = `Usage: umsctl [-o table|json] [-config FILE] <command> [arguments]
+
+Commands:
+  user create --name NAME --email EMAIL [--role ROLE]
+  user get <id|email>
+  user list
+  user update <id|email> [--name NAME] [--email EMAIL]
+  user delete <id|email>
+  user set-role <id|email> <role>
+  user import [--mode insert|upsert] <file.csv|file.ndjson>
+  user export [--format csv|ndjson]
+  otp purge [--older-than DURATION]
+  job list
+  job run <name>
+  session revoke <session-id> | --user <id|email>
+  token mint <id|email>
+  bootstrap-admin <email> [--name NAME]
+`
+
+func main() {
+	output := flag.String("o", "table", "output format: table or json")
+	configFile := flag.String("config", "", "path to a YAML config file (default $CONFIG_FILE)")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usageText) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Set once the configuration is loaded, before any command runs
+	var cfg *config.Config
+	commands := map[string]func(context.Context, *printer, []string) error{
+		"user":    runUser,
+		"otp":     runOTP,
+		"session": runSession,
+		"job": func(ctx context.Context, out *printer, args []string) error {
+			return runJob(ctx, out, cfg, args)
+		},
+		"token":           runToken,
+		"bootstrap-admin": runBootstrapAdmin,
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintln(os.Stderr, "error: -o must be table or json")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	if err := database.Connect(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	auth.SetJWTSecret(cfg.JWTSecret)
+	repository.SetTimeoutPolicy(repository.TimeoutPolicy{
+		Read:  cfg.DBReadTimeout,
+		Write: cfg.DBWriteTimeout,
+		Bulk:  cfg.DBBulkTimeout,
+	})
+
+	// Ctrl-C cancels in-flight queries instead of waiting for them
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = run(ctx, &printer{format: *output, w: os.Stdout}, args[1:])
+	stop()
+	database.CloseDB()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		if errors.Is(err, errUsage) {
+			flag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("invalid arguments")
+
+// printer renders command results as a table or JSON
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+// print writes v as JSON, or header and rows as an aligned table
+func (p *printer) print(v interface{}, header []string, rows [][]string) error {
+	if p.format == "json" {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (p *printer) users(users ...*models.User) error {
+	rows := make([][]string, 0, len(users))
+	for _, u := range users {
+		rows = append(rows, []string{strconv.Itoa(u.ID), u.Name, u.Email, u.Role})
+	}
+
+	var v interface{} = users
+	if len(users) == 1 {
+		v = users[0]
+	}
+	return p.print(v, []string{"ID", "NAME", "EMAIL", "ROLE"}, rows)
+}
+
+func (p *printer) message(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if p.format == "json" {
+		return p.print(map[string]string{"message": msg}, nil, nil)
+	}
+	_, err := fmt.Fprintln(p.w, msg)
+	return err
+}
+
+// findUser looks a user up by numeric ID or by email
+func findUser(ctx context.Context, ref string) (*models.User, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		user, err := repository.GetUserByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user %d not found", id)
+		}
+		return user, nil
+	}
+	return repository.GetUserByEmail(ctx, ref)
+}
+
+// parseFlags parses flags that may appear before or after positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
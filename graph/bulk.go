@@ -0,0 +1,35 @@
+package graph
+
+import (
+	"strings"
+
+	"user-management-service/graph/model"
+	"user-management-service/internal/bulk"
+)
+
+// importJobModel converts an import job to its GraphQL representation
+func importJobModel(job *bulk.Job) *model.ImportJob {
+	m := &model.ImportJob{
+		ID:         job.ID,
+		Status:     model.ImportJobStatus(job.Status),
+		Mode:       model.ImportMode(job.Mode),
+		Total:      job.Total,
+		Processed:  job.Processed,
+		Report:     job.Report,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	if job.Error != "" {
+		m.Error = &job.Error
+	}
+	return m
+}
+
+// importFormat picks the format of an uploaded file: the one asked for,
+// otherwise one detected from the upload
+func importFormat(format *model.ImportFormat, filename, contentType string) bulk.Format {
+	if format != nil {
+		return bulk.Format(strings.ToLower(string(*format)))
+	}
+	return bulk.DetectFormat(filename, contentType)
+}
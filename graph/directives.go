@@ -0,0 +1,20 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"user-management-service/internal/middleware"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// BlockImpersonation implements @blockImpersonation: sensitive mutations
+// (credentials, linked logins, deletions) cannot be performed while acting
+// as another user.
+func BlockImpersonation(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if userinfo := middleware.ForContext(ctx); userinfo != nil && userinfo.IsImpersonated() {
+		return nil, errors.New("access denied: not allowed while impersonating a user")
+	}
+	return next(ctx)
+}
@@ -0,0 +1,14 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// conflictError marks a lost update with the CONFLICT code, so clients can
+// tell it apart from other failures and reload before retrying
+func conflictError(err error) error {
+	gqlErr := gqlerror.Wrap(err)
+	errcode.Set(gqlErr, "CONFLICT")
+	return gqlErr
+}
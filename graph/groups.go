@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"user-management-service/internal/logging"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// requireAdmin returns the ID of the signed-in user if they are an admin
+func requireAdmin(ctx context.Context) (int, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil || !userinfo.HasRole(models.RoleAdmin) {
+		return 0, errors.New("access denied: admin role required")
+	}
+	return currentUserID(ctx)
+}
+
+// requireSelfOrAdmin allows the user id themselves and admins
+func requireSelfOrAdmin(ctx context.Context, id int) error {
+	if callerID, err := currentUserID(ctx); err == nil && callerID == id {
+		return nil
+	}
+	_, err := requireAdmin(ctx)
+	return err
+}
+
+// parseGroupID parses a group ID argument
+func parseGroupID(id string) (int, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, errors.New("invalid group ID format")
+	}
+	return n, nil
+}
+
+// parseUserIDs parses a list of user ID arguments
+func parseUserIDs(ids []string) ([]int, error) {
+	userIDs := make([]int, len(ids))
+	for i, id := range ids {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID format: %q", id)
+		}
+		userIDs[i] = n
+	}
+	return userIDs, nil
+}
+
+// validateGroupRoles rejects unknown roles
+func validateGroupRoles(roles []string) error {
+	for _, role := range roles {
+		if !models.IsValidRole(role) {
+			return fmt.Errorf("invalid role: %s", role)
+		}
+	}
+	return nil
+}
+
+// getGroup loads a group that a mutation has just changed
+func getGroup(ctx context.Context, id int) (*models.Group, error) {
+	group, err := repository.GetGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, repository.ErrGroupNotFound
+	}
+	return group, nil
+}
+
+// auditGroup records a change to a group. Changes to membership are
+// recorded once per user affected, so they show up in that user's log.
+func auditGroup(ctx context.Context, actorID int, action string, groupID int, subjectIDs []int, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["group_id"] = groupID
+
+	entries := []*models.AuditEntry{{ActorID: &actorID, Action: action, Metadata: metadata}}
+	if len(subjectIDs) > 0 {
+		entries = entries[:0]
+		for _, id := range subjectIDs {
+			entries = append(entries, &models.AuditEntry{ActorID: &actorID, SubjectID: &id, Action: action, Metadata: metadata})
+		}
+	}
+	for _, entry := range entries {
+		if err := repository.RecordAudit(ctx, entry); err != nil {
+			logging.FromContext(ctx).Error("Failed to record group change", "group_id", groupID, "action", action, "err", err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+)
+
+func TestEffectiveRolesRequireSelfOrAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *middleware.User
+		allowed bool
+	}{
+		{"anonymous", nil, false},
+		{"other user", &middleware.User{ID: "7", Role: models.RoleUser}, false},
+		{"support", &middleware.User{ID: "7", Role: models.RoleSupport}, false},
+		{"self", &middleware.User{ID: "5", Role: models.RoleUser}, true},
+		{"admin", &middleware.User{ID: "7", Role: models.RoleAdmin}, true},
+		{"admin through a group", &middleware.User{ID: "7", Role: models.RoleUser, Roles: []string{models.RoleAdmin, models.RoleUser}}, true},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.caller != nil {
+			ctx = context.WithValue(ctx, middleware.UserCtxKey, tt.caller)
+		}
+
+		if tt.allowed {
+			if err := requireSelfOrAdmin(ctx, 5); err != nil {
+				t.Errorf("%s: got %v, want access", tt.name, err)
+			}
+			continue
+		}
+		roles, err := (&userResolver{&Resolver{}}).EffectiveRoles(ctx, &models.User{ID: 5, Role: models.RoleAdmin})
+		if err == nil {
+			t.Errorf("%s: got effective roles %v, want access denied", tt.name, roles)
+		}
+	}
+}
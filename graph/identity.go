@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"user-management-service/internal/auth"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// currentUserID returns the numeric ID of the signed-in user
+func currentUserID(ctx context.Context) (int, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil {
+		return 0, errors.New("access denied: authentication required")
+	}
+
+	id, err := strconv.Atoi(userinfo.ID)
+	if err != nil {
+		return 0, errors.New("access denied: invalid session")
+	}
+	return id, nil
+}
+
+// linkToCurrentUser attaches a verified identity to the signed-in user
+func linkToCurrentUser(ctx context.Context, userID int, identity *models.Identity) (*models.Identity, error) {
+	linked, err := repository.GetIdentity(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up identity: %v", err)
+	}
+	if linked != nil {
+		if linked.UserID == userID {
+			return linked, nil
+		}
+		return nil, repository.ErrIdentityLinked
+	}
+
+	identity.UserID = userID
+	if err := repository.LinkIdentity(ctx, identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// requestEmailChange starts changing a user's email on behalf of actorID and
+// records the request in the audit log
+func requestEmailChange(ctx context.Context, actorID int, user *models.User, newEmail string) (*models.User, error) {
+	updated, err := auth.RequestEmailChange(ctx, user, newEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repository.RecordAudit(ctx, &models.AuditEntry{
+		ActorID:   &actorID,
+		SubjectID: &user.ID,
+		Action:    models.AuditEmailChangeRequested,
+		Metadata:  map[string]interface{}{"from": user.Email, "to": strings.TrimSpace(newEmail), "source": "graphql"},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to record email change request", "user_id", user.ID, "err", err)
+	}
+	return updated, nil
+}
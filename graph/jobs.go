@@ -0,0 +1,27 @@
+package graph
+
+import (
+	"context"
+
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// auditJobRun records a job an admin ran by hand
+func auditJobRun(ctx context.Context, actorID int, run *models.JobRun) {
+	err := repository.RecordAudit(ctx, &models.AuditEntry{
+		ActorID: &actorID,
+		Action:  models.AuditJobRun,
+		Metadata: map[string]interface{}{
+			"job":      run.Job,
+			"run_id":   run.ID,
+			"status":   run.Status,
+			"affected": run.Affected,
+			"source":   "graphql",
+		},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to record job run", "job", run.Job, "err", err)
+	}
+}
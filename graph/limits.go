@@ -0,0 +1,156 @@
+package graph
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	"user-management-service/internal/metrics"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Limits rejects operations whose cost, computed from the @cost annotations
+// in the schema, or selection depth exceeds the configured maximum. Zero
+// disables a limit. Both values are recorded so the limits can be tuned.
+type Limits struct {
+	MaxComplexity int
+	MaxDepth      int
+
+	es graphql.ExecutableSchema
+}
+
+var (
+	_ graphql.HandlerExtension        = &Limits{}
+	_ graphql.OperationContextMutator = &Limits{}
+)
+
+// ExtensionName implements graphql.HandlerExtension
+func (l *Limits) ExtensionName() string { return "Limits" }
+
+// Validate implements graphql.HandlerExtension
+func (l *Limits) Validate(es graphql.ExecutableSchema) error {
+	l.es = costSchema{ExecutableSchema: es, costs: fieldCosts(es.Schema())}
+	return nil
+}
+
+// MutateOperationContext implements graphql.OperationContextMutator
+func (l *Limits) MutateOperationContext(ctx context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	if oc.Operation == nil {
+		return nil
+	}
+
+	cost := complexity.Calculate(ctx, l.es, oc.Operation, oc.Variables)
+	depth := selectionDepth(oc.Operation.SelectionSet, map[string]bool{})
+	metrics.GraphQLCost(cost, depth)
+
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		metrics.GraphQLRejected("depth")
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, l.MaxDepth)
+		errcode.Set(err, "DEPTH_LIMIT_EXCEEDED")
+		return err
+	}
+	if l.MaxComplexity > 0 && cost > l.MaxComplexity {
+		metrics.GraphQLRejected("complexity")
+		err := gqlerror.Errorf("operation has complexity %d, which exceeds the limit of %d", cost, l.MaxComplexity)
+		errcode.Set(err, "COMPLEXITY_LIMIT_EXCEEDED")
+		return err
+	}
+	return nil
+}
+
+// selectionDepth is the deepest field nesting in set. Introspection fields
+// are skipped; introspection is only enabled in development. visited guards
+// against fragment cycles, which validation rejects anyway.
+func selectionDepth(set ast.SelectionSet, visited map[string]bool) int {
+	depth := 0
+	for _, selection := range set {
+		var d int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if s.Name == "__schema" || s.Name == "__type" {
+				continue
+			}
+			d = 1 + selectionDepth(s.SelectionSet, visited)
+		case *ast.InlineFragment:
+			d = selectionDepth(s.SelectionSet, visited)
+		case *ast.FragmentSpread:
+			if visited[s.Name] || s.Definition == nil {
+				continue
+			}
+			visited[s.Name] = true
+			d = selectionDepth(s.Definition.SelectionSet, visited)
+			delete(visited, s.Name)
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
+
+// cost is a parsed @cost annotation
+type cost struct {
+	weight   int
+	sizeArg  string
+	listSize int
+}
+
+// fieldCosts collects the @cost annotations, keyed by "Type.field"
+func fieldCosts(schema *ast.Schema) map[string]cost {
+	costs := make(map[string]cost)
+	for _, def := range schema.Types {
+		for _, field := range def.Fields {
+			d := field.Directives.ForName("cost")
+			if d == nil {
+				continue
+			}
+
+			c := cost{weight: 1, listSize: 1}
+			if arg := d.Arguments.ForName("weight"); arg != nil {
+				c.weight, _ = strconv.Atoi(arg.Value.Raw)
+			}
+			if arg := d.Arguments.ForName("sizeArg"); arg != nil {
+				c.sizeArg = arg.Value.Raw
+			}
+			if arg := d.Arguments.ForName("listSize"); arg != nil {
+				c.listSize, _ = strconv.Atoi(arg.Value.Raw)
+			}
+			costs[def.Name+"."+field.Name] = c
+		}
+	}
+	return costs
+}
+
+// costSchema answers complexity queries from the @cost annotations
+type costSchema struct {
+	graphql.ExecutableSchema
+	costs map[string]cost
+}
+
+// Complexity implements graphql.ExecutableSchema
+func (s costSchema) Complexity(ctx context.Context, typeName, field string, childComplexity int, args map[string]interface{}) (int, bool) {
+	c, ok := s.costs[typeName+"."+field]
+	if !ok {
+		return s.ExecutableSchema.Complexity(ctx, typeName, field, childComplexity, args)
+	}
+
+	size := int64(c.listSize)
+	if c.sizeArg != "" {
+		switch n := args[c.sizeArg].(type) {
+		case int:
+			size = int64(n)
+		case int64:
+			size = n
+		}
+	}
+	size = max(size, 1)
+
+	// Saturate instead of overflowing, which would let a huge size through
+	if childComplexity > 0 && size > (math.MaxInt-int64(c.weight))/int64(childComplexity) {
+		return math.MaxInt, true
+	}
+	return c.weight + int(size)*childComplexity, true
+}
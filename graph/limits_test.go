@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// execute runs a request against a server with the given extensions and no
+// database, returning the error codes; the checks under test run before
+// any resolver
+func execute(t *testing.T, body map[string]interface{}, exts ...graphql.HandlerExtension) []string {
+	t.Helper()
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  &Resolver{},
+		Directives: DirectiveRoot{BlockImpersonation: BlockImpersonation},
+	}))
+	srv.AddTransport(transport.POST{})
+	for _, ext := range exts {
+		srv.Use(ext)
+	}
+
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(data)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resp struct {
+		Errors []struct {
+			Message    string
+			Extensions map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var codes []string
+	for _, e := range resp.Errors {
+		code, _ := e.Extensions["code"].(string)
+		if code == "" {
+			code = e.Message
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+func TestComplexityLimit(t *testing.T) {
+	limits := &Limits{MaxComplexity: 500}
+
+	// users: weight 10 + listSize 100 * 2 fields
+	codes := execute(t, map[string]interface{}{"query": "{ users { id name } }"}, limits)
+	if len(codes) == 0 || codes[0] == "COMPLEXITY_LIMIT_EXCEEDED" {
+		t.Errorf("cost 210 query: got %v, want only the resolver's access error", codes)
+	}
+
+	// auditLog: weight 5 + limit 100 * 7 fields
+	codes = execute(t, map[string]interface{}{
+		"query": "{ auditLog(limit: 100) { id actorId subjectId action reason metadata createdAt } }",
+	}, limits)
+	if len(codes) != 1 || codes[0] != "COMPLEXITY_LIMIT_EXCEEDED" {
+		t.Errorf("cost 705 query: got %v, want COMPLEXITY_LIMIT_EXCEEDED", codes)
+	}
+}
+
+func TestDepthLimit(t *testing.T) {
+	limits := &Limits{MaxDepth: 2}
+
+	codes := execute(t, map[string]interface{}{
+		"query": "query { ...Audit } fragment Audit on Query { auditLog { actor { id } } }",
+	}, limits)
+	if len(codes) != 1 || codes[0] != "DEPTH_LIMIT_EXCEEDED" {
+		t.Errorf("got %v, want DEPTH_LIMIT_EXCEEDED", codes)
+	}
+}
+
+func TestAllowList(t *testing.T) {
+	allowed := "{ me { id } }"
+	path := filepath.Join(t.TempDir(), "allowlist.json")
+	manifest, _ := json.Marshal(map[string]string{queryHash(allowed): allowed})
+	if err := os.WriteFile(path, manifest, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	allowList, err := LoadAllowList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apq := extension.AutomaticPersistedQuery{Cache: lru.New[string](10)}
+
+	if codes := execute(t, map[string]interface{}{"query": "{ users { id } }"}, allowList, apq); len(codes) != 1 || codes[0] != "PERSISTED_QUERY_NOT_ALLOWED" {
+		t.Errorf("unlisted query: got %v, want PERSISTED_QUERY_NOT_ALLOWED", codes)
+	}
+	if codes := execute(t, map[string]interface{}{"query": allowed}, allowList, apq); len(codes) != 0 {
+		t.Errorf("listed query: got %v, want no errors", codes)
+	}
+
+	hashOnly := map[string]interface{}{
+		"extensions": map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": queryHash(allowed)},
+		},
+	}
+	if codes := execute(t, hashOnly, allowList, apq); len(codes) != 0 {
+		t.Errorf("listed hash: got %v, want no errors", codes)
+	}
+}
+
+func TestLoadAllowListRejectsWrongHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist.json")
+	if err := os.WriteFile(path, []byte(`{"0000": "{ me { id } }"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAllowList(path); err == nil {
+		t.Error("expected a hash mismatch error")
+	}
+}
@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"user-management-service/internal/config"
+	"user-management-service/internal/database"
+	"user-management-service/internal/loaders"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// statementCounter counts the SQL statements sent through a pool
+type statementCounter struct{ n atomic.Int32 }
+
+func (c *statementCounter) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	c.n.Add(1)
+	return ctx
+}
+
+func (c *statementCounter) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+func TestUserLookupsAreBatched(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &statementCounter{}
+	poolConfig.ConnConfig.Tracer = counter
+	database.DB, err = pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	// One aliased user(id:) field per user; gqlgen resolves them concurrently
+	var query strings.Builder
+	query.WriteString("{")
+	for i := 0; i < 100; i++ {
+		user := &models.User{Name: "Batch", Email: fmt.Sprintf("batch-%d-%d@example.com", os.Getpid(), i), Role: models.RoleUser}
+		if err := repository.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repository.DeleteUser(ctx, user.ID, nil, "test") })
+		fmt.Fprintf(&query, " u%d: user(id: \"%d\") { id email }", i, user.ID)
+	}
+	query.WriteString(" }")
+
+	srv := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: &Resolver{Config: config.Default()}}))
+	body, _ := json.Marshal(map[string]string{"query": query.String()})
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	counter.n.Store(0)
+	loaders.Middleware(srv).ServeHTTP(w, req)
+
+	var resp struct {
+		Data   map[string]*models.User
+		Errors []interface{}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) > 0 || len(resp.Data) != 100 {
+		t.Fatalf("got %d users, errors %v", len(resp.Data), resp.Errors)
+	}
+	for alias, user := range resp.Data {
+		if user == nil {
+			t.Errorf("%s resolved to null", alias)
+		}
+	}
+	if n := counter.n.Load(); n != 1 {
+		t.Errorf("resolving 100 users issued %d SQL statements, want 1", n)
+	}
+}
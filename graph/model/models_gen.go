@@ -3,6 +3,12 @@
 package model
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"user-management-service/internal/bulk"
 	"user-management-service/internal/models"
 )
 
@@ -11,8 +17,254 @@ type AuthResponse struct {
 	User  *models.User `json:"user"`
 }
 
+type CreatedAPIKey struct {
+	APIKey *models.APIKey `json:"apiKey"`
+	// The full key. It is only shown once and cannot be recovered.
+	Key string `json:"key"`
+}
+
+// A ZIP archive of everything stored about a user, from exportMyData
+type DataExport struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	// The archive, base64 encoded
+	Content string `json:"content"`
+}
+
+type ImpersonationResponse struct {
+	// Short-lived token for the impersonated user, carrying the actor in its act claim
+	Token     string       `json:"token"`
+	User      *models.User `json:"user"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+}
+
+// A user import. Imports of more than 1000 rows run in the background; poll
+// importJob until the status is SUCCEEDED or FAILED. Jobs are kept in memory
+// for an hour after they finish, on the server that ran them.
+type ImportJob struct {
+	ID     string          `json:"id"`
+	Status ImportJobStatus `json:"status"`
+	Mode   ImportMode      `json:"mode"`
+	// Valid rows to write
+	Total int `json:"total"`
+	// Rows written so far
+	Processed  int          `json:"processed"`
+	Report     *bulk.Report `json:"report"`
+	Error      *string      `json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	FinishedAt *time.Time   `json:"finishedAt,omitempty"`
+}
+
+// A maintenance job run by the scheduler
+type Job struct {
+	Name string `json:"name"`
+	// Cron schedule, in UTC
+	Schedule  string         `json:"schedule"`
+	NextRunAt time.Time      `json:"nextRunAt"`
+	LastRun   *models.JobRun `json:"lastRun,omitempty"`
+}
+
 type Mutation struct {
 }
 
+type OAuthClientRegistration struct {
+	Client *models.OAuthClient `json:"client"`
+	// Only returned once, at registration. Null for public (PKCE-only) clients.
+	ClientSecret *string `json:"clientSecret,omitempty"`
+}
+
 type Query struct {
 }
+
+// A login session that was revoked
+type RevokedSession struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+}
+
+// Live changes, delivered over WebSocket (graphql-ws). Send the JWT or API key
+// in the connection_init payload as {"Authorization": "Bearer <token>"}.
+// Admins receive changes to every user; others only changes to themselves.
+// A subscription ends when its session is revoked or the subscriber's role
+// changes; reconnect with a fresh token.
+type Subscription struct {
+}
+
+// Fields to change in updateUser. Fields left out or null keep their value.
+// A new email is not applied directly: it is confirmed as with requestEmailChange.
+type UpdateUserInput struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+}
+
+type ImportFormat string
+
+const (
+	ImportFormatCSV    ImportFormat = "CSV"
+	ImportFormatNdjson ImportFormat = "NDJSON"
+)
+
+var AllImportFormat = []ImportFormat{
+	ImportFormatCSV,
+	ImportFormatNdjson,
+}
+
+func (e ImportFormat) IsValid() bool {
+	switch e {
+	case ImportFormatCSV, ImportFormatNdjson:
+		return true
+	}
+	return false
+}
+
+func (e ImportFormat) String() string {
+	return string(e)
+}
+
+func (e *ImportFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportFormat", str)
+	}
+	return nil
+}
+
+func (e ImportFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ImportFormat) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ImportFormat) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ImportJobStatus string
+
+const (
+	ImportJobStatusPending   ImportJobStatus = "PENDING"
+	ImportJobStatusRunning   ImportJobStatus = "RUNNING"
+	ImportJobStatusSucceeded ImportJobStatus = "SUCCEEDED"
+	ImportJobStatusFailed    ImportJobStatus = "FAILED"
+)
+
+var AllImportJobStatus = []ImportJobStatus{
+	ImportJobStatusPending,
+	ImportJobStatusRunning,
+	ImportJobStatusSucceeded,
+	ImportJobStatusFailed,
+}
+
+func (e ImportJobStatus) IsValid() bool {
+	switch e {
+	case ImportJobStatusPending, ImportJobStatusRunning, ImportJobStatusSucceeded, ImportJobStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e ImportJobStatus) String() string {
+	return string(e)
+}
+
+func (e *ImportJobStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportJobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportJobStatus", str)
+	}
+	return nil
+}
+
+func (e ImportJobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ImportJobStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ImportJobStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+// What an import does with users that already exist, matched by email
+type ImportMode string
+
+const (
+	// Report them as failed rows
+	ImportModeInsert ImportMode = "INSERT"
+	// Update their name and, when the row has one, their role
+	ImportModeUpsert ImportMode = "UPSERT"
+)
+
+var AllImportMode = []ImportMode{
+	ImportModeInsert,
+	ImportModeUpsert,
+}
+
+func (e ImportMode) IsValid() bool {
+	switch e {
+	case ImportModeInsert, ImportModeUpsert:
+		return true
+	}
+	return false
+}
+
+func (e ImportMode) String() string {
+	return string(e)
+}
+
+func (e *ImportMode) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImportMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImportMode", str)
+	}
+	return nil
+}
+
+func (e ImportMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ImportMode) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ImportMode) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-service/internal/auth"
+	"user-management-service/internal/config"
+	"user-management-service/internal/database"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestAuthenticateRejectsAccessTokens(t *testing.T) {
+	token, err := auth.GenerateAccessJWT("session", "1", "rp@example.com", models.RoleAdmin, "openid email", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := middleware.Authenticate(context.Background(), "Bearer "+token, ""); !errors.Is(err, middleware.ErrWrongTokenType) {
+		t.Errorf("access token: got %v, want %v", err, middleware.ErrWrongTokenType)
+	}
+
+	session, err := auth.GenerateJWT("session", "1", "rp@example.com", models.RoleAdmin, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := middleware.AuthenticateAccessToken(context.Background(), "Bearer "+session); !errors.Is(err, middleware.ErrWrongTokenType) {
+		t.Errorf("session token at userinfo: got %v, want %v", err, middleware.ErrWrongTokenType)
+	}
+}
+
+func TestGraphQLRejectsOAuthAccessTokens(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	var err error
+	database.DB, err = pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	user := &models.User{Name: "RP", Email: fmt.Sprintf("rp-%d@example.com", os.Getpid()), Role: models.RoleAdmin}
+	if err := repository.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repository.DeleteUser(ctx, user.ID, nil, "test") })
+
+	srv := middleware.AuthMiddleware()(handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: &Resolver{Config: config.Default()}})))
+	me := func(token string) *string {
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ me { id } }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		var resp struct {
+			Data struct {
+				Me *struct{ ID string }
+			}
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %s: %v", w.Body.String(), err)
+		}
+		if resp.Data.Me == nil {
+			return nil
+		}
+		return &resp.Data.Me.ID
+	}
+
+	session, err := auth.IssueSession(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := me(session); id == nil || *id != fmt.Sprint(user.ID) {
+		t.Fatalf("session token: me = %v, want user %d", id, user.ID)
+	}
+
+	accessToken, err := auth.IssueAccessToken(ctx, user, "openid email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := me(accessToken); id != nil {
+		t.Errorf("OAuth access token was accepted by /graphql as user %s", *id)
+	}
+}
@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"user-management-service/internal/metrics"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// AllowList only lets through operations registered ahead of time. Clients
+// may send the full query or, as with automatic persisted queries, just its
+// SHA-256 hash in extensions.persistedQuery.sha256Hash.
+type AllowList struct {
+	queries map[string]string
+	// names of the operations in queries
+	operations map[string]bool
+}
+
+var (
+	_ graphql.HandlerExtension          = AllowList{}
+	_ graphql.OperationParameterMutator = AllowList{}
+)
+
+// LoadAllowList reads a JSON object mapping SHA-256 hex hashes to queries,
+// as produced by persisted query tooling. Every hash is checked against
+// its query, and every query must parse.
+func LoadAllowList(path string) (AllowList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AllowList{}, fmt.Errorf("reading persisted query allow-list: %v", err)
+	}
+
+	var queries map[string]string
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return AllowList{}, fmt.Errorf("parsing persisted query allow-list %s: %v", path, err)
+	}
+	operations := make(map[string]bool)
+	for hash, query := range queries {
+		if queryHash(query) != hash {
+			return AllowList{}, fmt.Errorf("persisted query allow-list %s: hash %s does not match its query", path, hash)
+		}
+		doc, err := parser.ParseQuery(&ast.Source{Input: query})
+		if err != nil {
+			return AllowList{}, fmt.Errorf("persisted query allow-list %s: query %s: %v", path, hash, err)
+		}
+		for _, op := range doc.Operations {
+			if op.Name != "" {
+				operations[op.Name] = true
+			}
+		}
+	}
+	return AllowList{queries: queries, operations: operations}, nil
+}
+
+// OperationNames returns the names of the allowed operations
+func (a AllowList) OperationNames() map[string]bool {
+	return a.operations
+}
+
+// ExtensionName implements graphql.HandlerExtension
+func (AllowList) ExtensionName() string { return "AllowList" }
+
+// Validate implements graphql.HandlerExtension
+func (AllowList) Validate(graphql.ExecutableSchema) error { return nil }
+
+// MutateOperationParameters implements graphql.OperationParameterMutator.
+// It must run before the automatic persisted query extension so that
+// unknown queries are never added to its cache.
+func (a AllowList) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	hash := persistedQueryHash(params)
+	if params.Query != "" {
+		hash = queryHash(params.Query)
+	}
+
+	query, ok := a.queries[hash]
+	if !ok {
+		metrics.GraphQLRejected("not_allowlisted")
+		err := gqlerror.Errorf("operation is not in the persisted query allow-list")
+		errcode.Set(err, "PERSISTED_QUERY_NOT_ALLOWED")
+		return err
+	}
+	params.Query = query
+	return nil
+}
+
+// persistedQueryHash reads the hash sent by persisted query clients
+func persistedQueryHash(params *graphql.RawParams) string {
+	ext, _ := params.Extensions["persistedQuery"].(map[string]interface{})
+	hash, _ := ext["sha256Hash"].(string)
+	return hash
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
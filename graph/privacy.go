@@ -0,0 +1,27 @@
+package graph
+
+import (
+	"context"
+
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// recordPrivacyAudit logs an action a user took on their own data
+func recordPrivacyAudit(ctx context.Context, userID int, action string, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["source"] = "graphql"
+
+	err := repository.RecordAudit(ctx, &models.AuditEntry{
+		ActorID:   &userID,
+		SubjectID: &userID,
+		Action:    action,
+		Metadata:  metadata,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to record audit entry", "action", action, "user_id", userID, "err", err)
+	}
+}
@@ -3,16 +3,11 @@ package graph
 //go:generate go run github.com/99designs/gqlgen generate
 
 import (
-	"log"
-	"time"
 	"user-management-service/internal/config"
+	"user-management-service/internal/jobs"
 )
 
 type Resolver struct {
-	Config *config.Config
-}
-
-func (r *Resolver) TrackExecutionTime(start time.Time, name string) {
-	elapsed := time.Since(start)
-	log.Printf("Pure backend execution time for %s: %s", name, elapsed)
+	Config    *config.Config
+	Scheduler *jobs.Scheduler
 }
@@ -6,44 +6,86 @@ package graph
 // Code generated by github.com/99designs/gqlgen version v0.17.86
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"log"
 	"strconv"
+	"strings"
 	"time"
 	"user-management-service/graph/model"
 	"user-management-service/internal/auth"
+	"user-management-service/internal/bulk"
+	"user-management-service/internal/database"
 	emailpkg "user-management-service/internal/email"
+	"user-management-service/internal/events"
+	"user-management-service/internal/loaders"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/metrics"
 	"user-management-service/internal/middleware"
 	"user-management-service/internal/models"
+	"user-management-service/internal/oidc"
+	"user-management-service/internal/privacy"
 	"user-management-service/internal/repository"
+
+	"github.com/99designs/gqlgen/graphql"
 )
 
+// Actor is the resolver for the actor field.
+func (r *auditEntryResolver) Actor(ctx context.Context, obj *models.AuditEntry) (*models.User, error) {
+	if obj.ActorID == nil {
+		return nil, nil
+	}
+	return loaders.GetUser(ctx, *obj.ActorID)
+}
+
+// Subject is the resolver for the subject field.
+func (r *auditEntryResolver) Subject(ctx context.Context, obj *models.AuditEntry) (*models.User, error) {
+	if obj.SubjectID == nil {
+		return nil, nil
+	}
+	return loaders.GetUser(ctx, *obj.SubjectID)
+}
+
+// Members is the resolver for the members field.
+func (r *groupResolver) Members(ctx context.Context, obj *models.Group) ([]*models.User, error) {
+	return repository.GetGroupMembers(ctx, obj.ID)
+}
+
+// Subgroups is the resolver for the subgroups field.
+func (r *groupResolver) Subgroups(ctx context.Context, obj *models.Group) ([]*models.Group, error) {
+	return repository.GetSubgroups(ctx, obj.ID)
+}
+
+// Parents is the resolver for the parents field.
+func (r *groupResolver) Parents(ctx context.Context, obj *models.Group) ([]*models.Group, error) {
+	return repository.GetParentGroups(ctx, obj.ID)
+}
+
 // CreateUser is the resolver for the createUser field.
 func (r *mutationResolver) CreateUser(ctx context.Context, name string, email string) (*models.User, error) {
-	defer r.TrackExecutionTime(time.Now(), "CreateUser")
 	userinfo := middleware.ForContext(ctx)
-	if userinfo == nil || userinfo.Role != models.RoleAdmin {
+	if userinfo == nil || !userinfo.HasRole(models.RoleAdmin) {
 		return nil, errors.New("access denied: admin role required")
 	}
 
 	user := &models.User{
 		Name:  name,
 		Email: email,
+		Role:  models.RoleUser,
 	}
-	if err := repository.CreateUser(user); err != nil {
+	if err := repository.CreateUser(ctx, user); err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
 // UpdateUser is the resolver for the updateUser field.
-func (r *mutationResolver) UpdateUser(ctx context.Context, id string, name string, email string) (*models.User, error) {
-	defer r.TrackExecutionTime(time.Now(), "UpdateUser")
-	userinfo := middleware.ForContext(ctx)
-	if userinfo == nil || userinfo.Role != models.RoleAdmin {
-		return nil, errors.New("access denied: admin role required")
+func (r *mutationResolver) UpdateUser(ctx context.Context, id string, input model.UpdateUserInput, expectedVersion *int) (*models.User, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	idInt, err := strconv.Atoi(id)
@@ -51,24 +93,30 @@ func (r *mutationResolver) UpdateUser(ctx context.Context, id string, name strin
 		return nil, errors.New("invalid user ID format")
 	}
 
-	user := &models.User{
-		ID:    idInt,
-		Name:  name,
-		Email: email,
+	if (input.Name != nil && strings.TrimSpace(*input.Name) == "") || (input.Email != nil && strings.TrimSpace(*input.Email) == "") {
+		return nil, errors.New("name and email cannot be empty")
 	}
 
-	if err := repository.UpdateUser(user); err != nil {
+	// The email is left to requestEmailChange, so it only changes once the
+	// new address is confirmed
+	user, err := repository.PatchUser(ctx, idInt, repository.UserPatch{Name: input.Name}, expectedVersion)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return nil, conflictError(err)
+	}
+	if err != nil {
 		return nil, err
 	}
+	if input.Email != nil && strings.TrimSpace(*input.Email) != user.Email {
+		return requestEmailChange(ctx, actorID, user, *input.Email)
+	}
 	return user, nil
 }
 
 // DeleteUser is the resolver for the deleteUser field.
 func (r *mutationResolver) DeleteUser(ctx context.Context, id string) (bool, error) {
-	defer r.TrackExecutionTime(time.Now(), "DeleteUser")
-	userinfo := middleware.ForContext(ctx)
-	if userinfo == nil || userinfo.Role != models.RoleAdmin {
-		return false, errors.New("access denied: admin role required")
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return false, err
 	}
 
 	idInt, err := strconv.Atoi(id)
@@ -76,57 +124,170 @@ func (r *mutationResolver) DeleteUser(ctx context.Context, id string) (bool, err
 		return false, errors.New("invalid user ID format")
 	}
 
-	if err := repository.DeleteUser(idInt); err != nil {
+	if err := repository.DeleteUser(ctx, idInt, &actorID, "graphql"); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+// RequestEmailChange is the resolver for the requestEmailChange field.
+func (r *mutationResolver) RequestEmailChange(ctx context.Context, newEmail string) (bool, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	user, err := repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if user == nil {
+		return false, repository.ErrUserNotFound
+	}
+
+	if _, err := requestEmailChange(ctx, userID, user, newEmail); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ConfirmEmailChange is the resolver for the confirmEmailChange field.
+func (r *mutationResolver) ConfirmEmailChange(ctx context.Context, token string) (*models.User, error) {
+	user, previous, err := auth.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repository.RecordAudit(ctx, &models.AuditEntry{
+		ActorID:   &user.ID,
+		SubjectID: &user.ID,
+		Action:    models.AuditEmailChanged,
+		Metadata:  map[string]interface{}{"from": previous, "to": user.Email},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to record email change", "user_id", user.ID, "err", err)
+	}
+	return user, nil
+}
+
+// RequestAccountDeletion is the resolver for the requestAccountDeletion field.
+func (r *mutationResolver) RequestAccountDeletion(ctx context.Context) (*time.Time, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, repository.ErrUserNotFound
+	}
+
+	at, err := privacy.RequestDeletion(ctx, user, r.Config.AccountDeletionGracePeriod)
+	if err != nil {
+		return nil, err
+	}
+	recordPrivacyAudit(ctx, userID, models.AuditDeletionRequested, map[string]interface{}{"scheduled_at": at})
+	return &at, nil
+}
+
+// CancelAccountDeletion is the resolver for the cancelAccountDeletion field.
+func (r *mutationResolver) CancelAccountDeletion(ctx context.Context) (bool, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	cancelled, err := privacy.CancelDeletion(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if cancelled {
+		recordPrivacyAudit(ctx, userID, models.AuditDeletionCancelled, nil)
+	}
+	return cancelled, nil
+}
+
+// SetUserRole is the resolver for the setUserRole field.
+func (r *mutationResolver) SetUserRole(ctx context.Context, id string, role string) (*models.User, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID format")
+	}
+	role = strings.ToUpper(strings.TrimSpace(role))
+	if !models.IsValidRole(role) {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
+	previous, err := repository.SetUserRole(ctx, idInt, role)
+	if err != nil {
+		return nil, err
+	}
+	if previous != role {
+		err = repository.RecordAudit(ctx, &models.AuditEntry{
+			ActorID:   &actorID,
+			SubjectID: &idInt,
+			Action:    models.AuditRoleChanged,
+			Metadata:  map[string]interface{}{"from": previous, "to": role, "source": "graphql"},
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to record role change", "user_id", idInt, "err", err)
+		}
+	}
+
+	user, err := repository.GetUserByID(ctx, idInt)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, repository.ErrUserNotFound
+	}
+	// The read may have gone to a replica that has not seen the change yet
+	user.Role = role
+	return user, nil
+}
+
 // LoginWithGoogle is the resolver for the loginWithGoogle field.
 func (r *mutationResolver) LoginWithGoogle(ctx context.Context, idToken string) (*model.AuthResponse, error) {
-	defer r.TrackExecutionTime(time.Now(), "LoginWithGoogle")
-
 	// 1. Verify Google Token
-	email, err := auth.VerifyGoogleToken(ctx, idToken, "") // Client ID empty for mock/demo
+	google, err := auth.VerifyGoogleToken(ctx, idToken, "") // Client ID empty for mock/demo
 	if err != nil {
 		return nil, fmt.Errorf("google auth failed: %v", err)
 	}
 
-	// 2. Find or Create User by Email
-	user, err := repository.GetUserByEmail(email)
-	if err != nil {
-		// If user doesn't exist, create a new one
-		user = &models.User{
+	// 2. Find the user linked to this Google account, or create one
+	user, err := auth.SignInIdentity(
+		ctx,
+		&models.Identity{Provider: models.ProviderGoogle, Subject: google.Subject, Email: google.Email},
+		&models.User{
 			Name:  "Google User", // Fallback name
-			Email: email,
+			Email: google.Email,
 			Role:  models.RoleUser,
-		}
-		if err := repository.CreateUser(user); err != nil {
-			return nil, fmt.Errorf("failed to create user: %v", err)
-		}
+		},
+		google.EmailVerified,
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	// 3. Generate JWT
-	token, err := auth.GenerateJWT(strconv.Itoa(user.ID), user.Email, user.Role)
+	token, err := auth.IssueSession(ctx, user)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate session: %v", err)
 	}
 
-	return &model.AuthResponse{
-		Token: token,
-		User: &models.User{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
-			Role:  user.Role,
-		},
-	}, nil
+	return &model.AuthResponse{Token: token, User: user}, nil
 }
 
 // RequestOtp is the resolver for the requestOtp field.
 func (r *mutationResolver) RequestOtp(ctx context.Context, email string) (*string, error) {
-	defer r.TrackExecutionTime(time.Now(), "RequestOtp")
-
 	// 1. Generate 6-digit OTP
 	otp, err := auth.GenerateOTP()
 	if err != nil {
@@ -141,13 +302,14 @@ func (r *mutationResolver) RequestOtp(ctx context.Context, email string) (*strin
 		ExpiresAt: expiresAt,
 	}
 
-	if err := repository.SaveOTP(otpModel); err != nil {
+	if err := repository.SaveOTP(ctx, otpModel); err != nil {
 		return nil, fmt.Errorf("failed to save OTP: %v", err)
 	}
+	metrics.OTPIssued()
 
 	// 3. Send Email
-	if err := emailpkg.SendOTPEmail(email, otp); err != nil {
-		log.Printf("Email sending failed (but OTP saved): %v", err)
+	if err := emailpkg.SendOTPEmail(ctx, email, otp); err != nil {
+		logging.FromContext(ctx).Error("Email sending failed (but OTP saved)", "err", err)
 		// We don't return error here if we want the user to still be able to use the OTP
 		// but for security/ux we might want to know.
 	}
@@ -157,120 +319,715 @@ func (r *mutationResolver) RequestOtp(ctx context.Context, email string) (*strin
 }
 
 // VerifyOtp is the resolver for the verifyOtp field.
-func (r *mutationResolver) VerifyOtp(ctx context.Context, email string, otp string, role *string) (*model.AuthResponse, error) {
-	defer r.TrackExecutionTime(time.Now(), "VerifyOtp")
+func (r *mutationResolver) VerifyOtp(ctx context.Context, email string, otp string) (*model.AuthResponse, error) {
+	// 1. Validate OTP and mark it as used
+	if err := auth.ConsumeOTP(ctx, email, otp); err != nil {
+		return nil, err
+	}
 
-	// 1. Get latest OTP from DB
-	latestOtp, err := repository.GetLatestOTP(email)
+	// 2. Find or Create User. New users start with the USER role; admins
+	// change roles with setUserRole.
+	user, err := auth.SignInWithEmail(ctx, email, models.RoleUser)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check OTP: %v", err)
+		return nil, err
 	}
 
-	if latestOtp == nil {
-		return nil, errors.New("no OTP request found for this email")
+	// 3. Generate JWT
+	token, err := auth.IssueSession(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session: %v", err)
 	}
 
-	// 2. Validate OTP
-	if latestOtp.IsUsed {
-		return nil, errors.New("OTP has already been used")
+	return &model.AuthResponse{Token: token, User: user}, nil
+}
+
+// LinkGoogleIdentity is the resolver for the linkGoogleIdentity field.
+func (r *mutationResolver) LinkGoogleIdentity(ctx context.Context, idToken string) (*models.Identity, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	google, err := auth.VerifyGoogleToken(ctx, idToken, "") // Client ID empty for mock/demo
+	if err != nil {
+		return nil, fmt.Errorf("google auth failed: %v", err)
 	}
 
-	if time.Now().After(latestOtp.ExpiresAt) {
-		return nil, errors.New("OTP has expired")
+	return linkToCurrentUser(ctx, userID, &models.Identity{
+		Provider: models.ProviderGoogle,
+		Subject:  google.Subject,
+		Email:    google.Email,
+	})
+}
+
+// LinkEmailIdentity is the resolver for the linkEmailIdentity field.
+func (r *mutationResolver) LinkEmailIdentity(ctx context.Context, email string, otp string) (*models.Identity, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := auth.ConsumeOTP(ctx, email, otp); err != nil {
+		return nil, err
 	}
 
-	if latestOtp.AttemptCount >= 3 {
-		return nil, errors.New("maximum verification attempts exceeded")
+	return linkToCurrentUser(ctx, userID, &models.Identity{
+		Provider: models.ProviderEmail,
+		Subject:  auth.EmailSubject(email),
+		Email:    email,
+	})
+}
+
+// UnlinkIdentity is the resolver for the unlinkIdentity field.
+func (r *mutationResolver) UnlinkIdentity(ctx context.Context, id string) (bool, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return false, err
 	}
 
-	if latestOtp.OTP != otp {
-		repository.IncrementOTPAttempts(latestOtp.ID)
-		return nil, errors.New("invalid OTP")
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return false, errors.New("invalid identity ID format")
 	}
 
-	// 3. Mark as Used
-	if err := repository.MarkOTPAsUsed(latestOtp.ID); err != nil {
-		return nil, fmt.Errorf("failed to finalize OTP: %v", err)
+	if err := repository.UnlinkIdentity(ctx, userID, idInt); err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	// 4. Find or Create User
-	user, err := repository.GetUserByEmail(email)
+// CreateAPIKey is the resolver for the createApiKey field.
+func (r *mutationResolver) CreateAPIKey(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (*model.CreatedAPIKey, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo != nil && userinfo.APIKeyID != 0 {
+		return nil, errors.New("access denied: api keys cannot create api keys")
+	}
+	userID, err := currentUserID(ctx)
 	if err != nil {
-		// Create user if not exists
-		defaultRole := models.RoleUser
-		if role != nil && *role != "" {
-			defaultRole = *role
-		}
-		user = &models.User{
-			Name:  "OTP User",
-			Email: email,
-			Role:  defaultRole,
-		}
-		if err := repository.CreateUser(user); err != nil {
-			return nil, fmt.Errorf("failed to create user: %v", err)
-		}
-	} else if role != nil && *role != "" && user.Role != *role {
-		// Update user role if explicitly requested and different (for testing/demo)
-		user.Role = *role
-		if err := repository.UpdateUser(user); err != nil {
-			log.Printf("Warning: Failed to update user role to %s: %v", *role, err)
+		return nil, err
+	}
+
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	for _, scope := range scopes {
+		if scope != models.ScopeRead && scope != models.ScopeWrite && scope != models.ScopeSCIM {
+			return nil, fmt.Errorf("unknown scope %q", scope)
 		}
 	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, errors.New("expiresAt must be in the future")
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %v", err)
+	}
+
+	apiKey := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := repository.CreateAPIKey(ctx, apiKey); err != nil {
+		return nil, err
+	}
+	return &model.CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// RevokeAPIKey is the resolver for the revokeApiKey field.
+func (r *mutationResolver) RevokeAPIKey(ctx context.Context, id string) (bool, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return false, errors.New("invalid api key ID format")
+	}
+
+	if err := repository.RevokeAPIKey(ctx, userID, idInt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RegisterOAuthClient is the resolver for the registerOAuthClient field.
+func (r *mutationResolver) RegisterOAuthClient(ctx context.Context, name string, redirectUris []string, public *bool) (*model.OAuthClientRegistration, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil || !userinfo.HasRole(models.RoleAdmin) {
+		return nil, errors.New("access denied: admin role required")
+	}
+
+	client, secret, err := oidc.RegisterClient(ctx, name, redirectUris, public != nil && *public)
+	if err != nil {
+		return nil, err
+	}
+
+	registration := &model.OAuthClientRegistration{Client: client}
+	if secret != "" {
+		registration.ClientSecret = &secret
+	}
+	return registration, nil
+}
+
+// ImpersonateUser is the resolver for the impersonateUser field.
+func (r *mutationResolver) ImpersonateUser(ctx context.Context, id string, reason string) (*model.ImpersonationResponse, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil || !userinfo.HasPermission(models.PermissionImpersonate) {
+		return nil, errors.New("access denied: impersonation permission required")
+	}
+	actorID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return nil, errors.New("a reason is required to impersonate a user")
+	}
+
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID format")
+	}
+	if idInt == actorID {
+		return nil, errors.New("cannot impersonate yourself")
+	}
+
+	// The privilege check below must not see a role change the replica
+	// has not caught up with
+	subject, err := repository.GetUserByID(database.WithPrimary(ctx), idInt)
+	if err != nil {
+		return nil, err
+	}
+	if subject == nil {
+		return nil, errors.New("user not found")
+	}
+	// Impersonating another privileged user would let support staff borrow
+	// admin rights, whether the user holds them directly or through a group
+	subjectRoles, err := repository.GetEffectiveRoles(ctx, []int{subject.ID})
+	if err != nil {
+		return nil, err
+	}
+	if models.RolesHavePermission(subjectRoles[subject.ID], models.PermissionImpersonate) {
+		return nil, errors.New("access denied: privileged users cannot be impersonated")
+	}
+
+	session := &models.ImpersonationSession{
+		ActorID:   actorID,
+		SubjectID: subject.ID,
+		Reason:    reason,
+		ExpiresAt: time.Now().Add(auth.ImpersonationTTL),
+	}
+	if err := repository.StartImpersonation(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to start impersonation: %v", err)
+	}
 
-	// 5. Generate JWT
-	token, err := auth.GenerateJWT(strconv.Itoa(user.ID), user.Email, user.Role)
+	token, err := auth.GenerateImpersonationJWT(
+		strconv.Itoa(session.ID),
+		strconv.Itoa(subject.ID), subject.Email, subject.Role,
+		auth.Actor{Subject: userinfo.ID, Email: userinfo.Email},
+		session.ExpiresAt,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate session: %v", err)
 	}
 
-	return &model.AuthResponse{
-		Token: token,
-		User: &models.User{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
-			Role:  user.Role,
-		},
-	}, nil
+	logging.FromContext(ctx).Info("Impersonation started", "subject_id", subject.ID)
+	return &model.ImpersonationResponse{Token: token, User: subject, ExpiresAt: session.ExpiresAt}, nil
+}
+
+// StopImpersonation is the resolver for the stopImpersonation field.
+func (r *mutationResolver) StopImpersonation(ctx context.Context) (bool, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil || !userinfo.IsImpersonated() {
+		return false, errors.New("not impersonating a user")
+	}
+
+	if err := repository.StopImpersonation(ctx, userinfo.ImpersonationSessionID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImportUsers is the resolver for the importUsers field.
+func (r *mutationResolver) ImportUsers(ctx context.Context, file graphql.Upload, mode *model.ImportMode, dryRun *bool, format *model.ImportFormat) (*model.ImportJob, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil || !userinfo.HasRole(models.RoleAdmin) {
+		return nil, errors.New("access denied: admin role required")
+	}
+	actorID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	importMode := bulk.ModeInsert
+	if mode != nil {
+		importMode = bulk.Mode(*mode)
+	}
+	rows, report, err := bulk.Parse(file.File, importFormat(format, file.Filename, file.ContentType))
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := bulk.Start(ctx, rows, importMode, report, dryRun != nil && *dryRun, &actorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import users: %v", err)
+	}
+	return importJobModel(job), nil
+}
+
+// CreateGroup is the resolver for the createGroup field.
+func (r *mutationResolver) CreateGroup(ctx context.Context, name string, description *string, roles []string) (*models.Group, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.Group{Name: strings.TrimSpace(name), Roles: roles}
+	if group.Name == "" {
+		return nil, errors.New("a group name is required")
+	}
+	if description != nil {
+		group.Description = strings.TrimSpace(*description)
+	}
+	if err := validateGroupRoles(roles); err != nil {
+		return nil, err
+	}
+
+	if err := repository.CreateGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	auditGroup(ctx, actorID, models.AuditGroupCreated, group.ID, nil, map[string]interface{}{"name": group.Name, "roles": roles})
+	return getGroup(ctx, group.ID)
+}
+
+// UpdateGroup is the resolver for the updateGroup field.
+func (r *mutationResolver) UpdateGroup(ctx context.Context, id string, name string, description string) (*models.Group, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.Group{ID: groupID, Name: strings.TrimSpace(name), Description: strings.TrimSpace(description)}
+	if group.Name == "" {
+		return nil, errors.New("a group name is required")
+	}
+	if err := repository.UpdateGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	auditGroup(ctx, actorID, models.AuditGroupUpdated, groupID, nil, map[string]interface{}{"name": group.Name})
+	return getGroup(ctx, groupID)
+}
+
+// DeleteGroup is the resolver for the deleteGroup field.
+func (r *mutationResolver) DeleteGroup(ctx context.Context, id string) (bool, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return false, err
+	}
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return false, err
+	}
+
+	if err := repository.DeleteGroup(ctx, groupID); err != nil {
+		return false, err
+	}
+	auditGroup(ctx, actorID, models.AuditGroupDeleted, groupID, nil, nil)
+	return true, nil
+}
+
+// SetGroupRoles is the resolver for the setGroupRoles field.
+func (r *mutationResolver) SetGroupRoles(ctx context.Context, id string, roles []string) (*models.Group, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateGroupRoles(roles); err != nil {
+		return nil, err
+	}
+
+	if err := repository.SetGroupRoles(ctx, groupID, roles); err != nil {
+		return nil, err
+	}
+	auditGroup(ctx, actorID, models.AuditGroupRolesChanged, groupID, nil, map[string]interface{}{"roles": roles})
+	return getGroup(ctx, groupID)
+}
+
+// AddGroupMembers is the resolver for the addGroupMembers field.
+func (r *mutationResolver) AddGroupMembers(ctx context.Context, id string, userIds []string) (*models.Group, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := parseUserIDs(userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := getGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	added, err := repository.AddGroupMembers(ctx, groupID, ids)
+	if err != nil {
+		return nil, err
+	}
+	auditGroup(ctx, actorID, models.AuditGroupMembersAdded, groupID, added, nil)
+	return group, nil
+}
+
+// RemoveGroupMembers is the resolver for the removeGroupMembers field.
+func (r *mutationResolver) RemoveGroupMembers(ctx context.Context, id string, userIds []string) (*models.Group, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := parseUserIDs(userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := getGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	removed, err := repository.RemoveGroupMembers(ctx, groupID, ids)
+	if err != nil {
+		return nil, err
+	}
+	auditGroup(ctx, actorID, models.AuditGroupMembersRemoved, groupID, removed, nil)
+	return group, nil
+}
+
+// AddSubgroup is the resolver for the addSubgroup field.
+func (r *mutationResolver) AddSubgroup(ctx context.Context, id string, subgroupID string) (*models.Group, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return nil, err
+	}
+	childID, err := parseGroupID(subgroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repository.AddSubgroup(ctx, groupID, childID); err != nil {
+		return nil, err
+	}
+	auditGroup(ctx, actorID, models.AuditSubgroupAdded, groupID, nil, map[string]interface{}{"subgroup_id": childID})
+	return getGroup(ctx, groupID)
+}
+
+// RemoveSubgroup is the resolver for the removeSubgroup field.
+func (r *mutationResolver) RemoveSubgroup(ctx context.Context, id string, subgroupID string) (*models.Group, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return nil, err
+	}
+	childID, err := parseGroupID(subgroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := repository.RemoveSubgroup(ctx, groupID, childID)
+	if err != nil {
+		return nil, err
+	}
+	if removed {
+		auditGroup(ctx, actorID, models.AuditSubgroupRemoved, groupID, nil, map[string]interface{}{"subgroup_id": childID})
+	}
+	return getGroup(ctx, groupID)
+}
+
+// RunJob is the resolver for the runJob field.
+func (r *mutationResolver) RunJob(ctx context.Context, name string) (*models.JobRun, error) {
+	actorID, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := r.Scheduler.RunNow(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, errors.New("the job has already run")
+	}
+	auditJobRun(ctx, actorID, run)
+	return run, nil
 }
 
 // Users is the resolver for the users field.
 func (r *queryResolver) Users(ctx context.Context) ([]*models.User, error) {
-	defer r.TrackExecutionTime(time.Now(), "Users")
 	userinfo := middleware.ForContext(ctx)
-	if userinfo == nil || userinfo.Role != models.RoleAdmin {
+	if userinfo == nil || !userinfo.HasRole(models.RoleAdmin) {
 		return nil, errors.New("access denied: admin role required")
 	}
-	return repository.GetAllUsers()
+	return repository.GetAllUsers(ctx)
 }
 
 // User is the resolver for the user field.
 func (r *queryResolver) User(ctx context.Context, id string) (*models.User, error) {
-	defer r.TrackExecutionTime(time.Now(), "User")
 	idInt, err := strconv.Atoi(id)
 	if err != nil {
 		return nil, errors.New("invalid user ID format")
 	}
-	return repository.GetUserByID(idInt)
+	return loaders.GetUser(ctx, idInt)
+}
+
+// SearchUsers is the resolver for the searchUsers field.
+func (r *queryResolver) SearchUsers(ctx context.Context, query string, limit *int) ([]*models.UserSearchResult, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	n := repository.DefaultSearchLimit
+	if limit != nil {
+		n = *limit
+	}
+	if n < 1 || n > repository.MaxSearchLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", repository.MaxSearchLimit)
+	}
+	return repository.SearchUsers(ctx, query, n)
 }
 
 // Me is the resolver for the me field.
 func (r *queryResolver) Me(ctx context.Context) (*models.User, error) {
-	defer r.TrackExecutionTime(time.Now(), "Me")
 	userinfo := middleware.ForContext(ctx)
 	if userinfo == nil {
 		return nil, nil // Return null if not authenticated
 	}
 
 	idInt, _ := strconv.Atoi(userinfo.ID)
-	return repository.GetUserByID(idInt)
+	return repository.GetUserByID(ctx, idInt)
+}
+
+// MyIdentities is the resolver for the myIdentities field.
+func (r *queryResolver) MyIdentities(ctx context.Context) ([]*models.Identity, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetIdentitiesByUser(ctx, userID)
+}
+
+// MyAPIKeys is the resolver for the myApiKeys field.
+func (r *queryResolver) MyAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetAPIKeysByUser(ctx, userID)
+}
+
+// ExportMyData is the resolver for the exportMyData field.
+func (r *queryResolver) ExportMyData(ctx context.Context) (*model.DataExport, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, repository.ErrUserNotFound
+	}
+
+	var buf bytes.Buffer
+	if err := privacy.WriteExport(ctx, &buf, user); err != nil {
+		return nil, err
+	}
+	recordPrivacyAudit(ctx, userID, models.AuditDataExported, nil)
+
+	return &model.DataExport{
+		Filename:    privacy.ExportFilename(user),
+		ContentType: privacy.ExportContentType,
+		Content:     base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// AuditLog is the resolver for the auditLog field.
+func (r *queryResolver) AuditLog(ctx context.Context, userID *string, limit *int) ([]*models.AuditEntry, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil || !userinfo.HasRole(models.RoleAdmin) {
+		return nil, errors.New("access denied: admin role required")
+	}
+
+	var subjectID *int
+	if userID != nil {
+		idInt, err := strconv.Atoi(*userID)
+		if err != nil {
+			return nil, errors.New("invalid user ID format")
+		}
+		subjectID = &idInt
+	}
+
+	max := 50
+	if limit != nil && *limit > 0 && *limit <= 500 {
+		max = *limit
+	}
+	return repository.GetAuditLog(ctx, subjectID, max)
+}
+
+// ImportJob is the resolver for the importJob field.
+func (r *queryResolver) ImportJob(ctx context.Context, id string) (*model.ImportJob, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil || !userinfo.HasRole(models.RoleAdmin) {
+		return nil, errors.New("access denied: admin role required")
+	}
+
+	job := bulk.GetJob(id)
+	if job == nil {
+		return nil, nil
+	}
+	return importJobModel(job), nil
+}
+
+// Groups is the resolver for the groups field.
+func (r *queryResolver) Groups(ctx context.Context) ([]*models.Group, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return repository.ListGroups(ctx)
+}
+
+// Group is the resolver for the group field.
+func (r *queryResolver) Group(ctx context.Context, id string) (*models.Group, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetGroup(ctx, groupID)
+}
+
+// Jobs is the resolver for the jobs field.
+func (r *queryResolver) Jobs(ctx context.Context) ([]*model.Job, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	latest, err := repository.GetLatestJobRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var result []*model.Job
+	for _, job := range r.Scheduler.Jobs() {
+		result = append(result, &model.Job{
+			Name:      job.Name,
+			Schedule:  job.Spec,
+			NextRunAt: job.Next(now),
+			LastRun:   latest[job.Name],
+		})
+	}
+	return result, nil
+}
+
+// JobRuns is the resolver for the jobRuns field.
+func (r *queryResolver) JobRuns(ctx context.Context, job *string, status *string, limit *int) ([]*models.JobRun, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	max := 50
+	if limit != nil && *limit > 0 && *limit <= 500 {
+		max = *limit
+	}
+	var jobName, jobStatus string
+	if job != nil {
+		jobName = *job
+	}
+	if status != nil {
+		jobStatus = strings.ToUpper(*status)
+	}
+	return repository.GetJobRuns(ctx, jobName, jobStatus, max)
 }
 
+// UserChanged is the resolver for the userChanged field.
+func (r *subscriptionResolver) UserChanged(ctx context.Context) (<-chan *models.User, error) {
+	return subscribe(ctx, func(e events.Event) (*models.User, bool) {
+		return e.User, e.Type == events.UserChanged && e.User != nil
+	})
+}
+
+// UserDeleted is the resolver for the userDeleted field.
+func (r *subscriptionResolver) UserDeleted(ctx context.Context) (<-chan string, error) {
+	return subscribe(ctx, func(e events.Event) (string, bool) {
+		return strconv.Itoa(e.UserID), e.Type == events.UserDeleted
+	})
+}
+
+// SessionRevoked is the resolver for the sessionRevoked field.
+func (r *subscriptionResolver) SessionRevoked(ctx context.Context) (<-chan *model.RevokedSession, error) {
+	return subscribe(ctx, func(e events.Event) (*model.RevokedSession, bool) {
+		return &model.RevokedSession{ID: e.SessionID, UserID: strconv.Itoa(e.UserID)}, e.Type == events.SessionRevoked
+	})
+}
+
+// EffectiveRoles is the resolver for the effectiveRoles field.
+func (r *userResolver) EffectiveRoles(ctx context.Context, obj *models.User) ([]string, error) {
+	if err := requireSelfOrAdmin(ctx, obj.ID); err != nil {
+		return nil, err
+	}
+	return loaders.GetEffectiveRoles(ctx, obj)
+}
+
+// AuditEntry returns AuditEntryResolver implementation.
+func (r *Resolver) AuditEntry() AuditEntryResolver { return &auditEntryResolver{r} }
+
+// Group returns GroupResolver implementation.
+func (r *Resolver) Group() GroupResolver { return &groupResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type auditEntryResolver struct{ *Resolver }
+type groupResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
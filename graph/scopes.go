@@ -0,0 +1,31 @@
+package graph
+
+import (
+	"context"
+
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// RequireScopes rejects operations an API key is not scoped for: queries
+// need the read scope and mutations the write scope.
+func RequireScopes(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	userinfo := middleware.ForContext(ctx)
+	oc := graphql.GetOperationContext(ctx)
+	if userinfo == nil || oc.Operation == nil {
+		return next(ctx)
+	}
+
+	scope := models.ScopeRead
+	if oc.Operation.Operation == ast.Mutation {
+		scope = models.ScopeWrite
+	}
+
+	if !userinfo.HasScope(scope) {
+		return graphql.OneShot(graphql.ErrorResponse(ctx, "access denied: api key lacks the %s scope", scope))
+	}
+	return next(ctx)
+}
@@ -0,0 +1,107 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+
+	"user-management-service/internal/events"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// WebsocketInit authenticates a graphql-ws connection. Browsers cannot set
+// headers on WebSocket requests, so credentials may instead be sent in the
+// connection_init payload as "Authorization" or "X-API-Key".
+func WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	authorization, apiKey := payload.Authorization(), payload.GetString("X-API-Key")
+	if authorization == "" && apiKey == "" {
+		return ctx, &payload, nil
+	}
+
+	ctx, err := middleware.Authenticate(ctx, authorization, apiKey)
+	if err != nil {
+		return nil, nil, errors.New("access denied: invalid credentials")
+	}
+	return ctx, &payload, nil
+}
+
+// subscribe relays the events the caller may see, as converted by convert,
+// until the subscription ends or the caller's credentials stop being valid:
+// the token expires, its session is revoked, or the user's effective roles
+// change, directly or through a group.
+func subscribe[T any](ctx context.Context, convert func(events.Event) (T, bool)) (<-chan T, error) {
+	userinfo := middleware.ForContext(ctx)
+	if userinfo == nil {
+		return nil, errors.New("access denied: authentication required")
+	}
+	userID, _ := strconv.Atoi(userinfo.ID)
+	canSeeAll := userinfo.HasRole(models.RoleAdmin)
+
+	var cancel context.CancelFunc
+	if userinfo.ExpiresAt.IsZero() {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithDeadline(ctx, userinfo.ExpiresAt)
+	}
+
+	in := events.Subscribe(ctx)
+	out := make(chan T)
+	go func() {
+		defer cancel()
+		defer close(out)
+
+		for e := range in {
+			if canSeeAll || e.UserID == userID {
+				if v, ok := convert(e); ok {
+					select {
+					case out <- v:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			if invalidates(ctx, userinfo, userID, e) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// invalidates reports whether e revokes the credentials a subscription was made with
+func invalidates(ctx context.Context, userinfo *middleware.User, userID int, e events.Event) bool {
+	switch e.Type {
+	case events.SessionRevoked:
+		return userinfo.SessionID != "" && e.SessionID == userinfo.SessionID
+	case events.UserChanged:
+		return e.UserID == userID && rolesChanged(ctx, userinfo, userID)
+	case events.GroupsChanged:
+		return rolesChanged(ctx, userinfo, userID)
+	case events.UserDeleted:
+		return e.UserID == userID
+	}
+	return false
+}
+
+// rolesChanged reports whether the user's effective roles differ from those
+// the subscription was made with. A failed lookup counts as a change, so a
+// subscription never outlives a role it can no longer confirm.
+func rolesChanged(ctx context.Context, userinfo *middleware.User, userID int) bool {
+	roles, err := repository.GetEffectiveRoles(ctx, []int{userID})
+	if err != nil {
+		logging.FromContext(ctx).Warn("Ending subscription, effective roles unavailable", "err", err)
+		return true
+	}
+
+	granted := userinfo.Roles
+	if granted == nil {
+		granted = []string{userinfo.Role}
+	}
+	return !slices.Equal(roles[userID], granted)
+}
@@ -0,0 +1,174 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/events"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/metrics"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+	"user-management-service/internal/tracing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
+)
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// subscribeAs opens a graphql-ws subscription as user, through the same
+// middleware the service wraps /graphql in, which must support hijacking
+func subscribeAs(t *testing.T, user *middleware.User, query string) *websocket.Conn {
+	t.Helper()
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: &Resolver{}}))
+	srv.AddTransport(transport.Websocket{InitFunc: WebsocketInit})
+
+	withUser := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), middleware.UserCtxKey, user)
+		metrics.HTTPMiddleware(srv).ServeHTTP(w, r.WithContext(ctx))
+	})
+	ts := httptest.NewServer(tracing.Handler(logging.Middleware(withUser)))
+	t.Cleanup(ts.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	send := func(m wsMessage) {
+		if err := conn.WriteJSON(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(wsMessage{Type: "connection_init"})
+	if m := read(t, conn); m.Type != "connection_ack" {
+		t.Fatalf("got %+v, want connection_ack", m)
+	}
+	payload, _ := json.Marshal(map[string]string{"query": query})
+	send(wsMessage{ID: "1", Type: "subscribe", Payload: payload})
+
+	// Events published before the resolver has subscribed would be missed
+	time.Sleep(50 * time.Millisecond)
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var m wsMessage
+		if err := conn.ReadJSON(&m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != "ping" && m.Type != "pong" {
+			return m
+		}
+	}
+}
+
+func userChanged(id int, role string) events.Event {
+	return events.Event{Type: events.UserChanged, UserID: id, User: &models.User{ID: id, Name: "n", Email: "e@example.com", Role: role}}
+}
+
+func TestUserChangedOnlyShowsOwnChangesToNonAdmins(t *testing.T) {
+	conn := subscribeAs(t, &middleware.User{ID: "5", Role: models.RoleUser}, "subscription { userChanged { id } }")
+
+	events.Publish(userChanged(7, models.RoleUser))
+	events.Publish(userChanged(5, models.RoleUser))
+
+	m := read(t, conn)
+	var next struct {
+		Data struct{ UserChanged struct{ ID string } }
+	}
+	json.Unmarshal(m.Payload, &next)
+	if m.Type != "next" || next.Data.UserChanged.ID != "5" {
+		t.Errorf("got %+v, want the change to user 5 only", m)
+	}
+}
+
+func TestSubscriptionEndsWhenSessionIsRevoked(t *testing.T) {
+	conn := subscribeAs(t, &middleware.User{ID: "5", Role: models.RoleAdmin, SessionID: "s1"}, "subscription { sessionRevoked { id userId } }")
+
+	events.Publish(events.Event{Type: events.SessionRevoked, UserID: 5, SessionID: "s1"})
+
+	if m := read(t, conn); m.Type != "next" {
+		t.Fatalf("got %+v, want the revocation", m)
+	}
+	if m := read(t, conn); m.Type != "complete" {
+		t.Errorf("got %+v, want the subscription to complete", m)
+	}
+}
+
+func TestSubscriptionEndsWhenGroupRoleIsLost(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	if err := database.ConnectDB(ctx, databaseURL, database.DefaultPoolSettings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	suffix := time.Now().UnixNano()
+	user := &models.User{Name: "Grouped", Email: fmt.Sprintf("grouped-sub-%d@example.com", suffix), Role: models.RoleUser}
+	if err := repository.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repository.DeleteUser(context.Background(), user.ID, nil, "test") })
+	group := &models.Group{Name: fmt.Sprintf("sub-admins-%d", suffix), Roles: []string{models.RoleAdmin}}
+	if err := repository.CreateGroup(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repository.DeleteGroup(context.Background(), group.ID) })
+	if _, err := repository.AddGroupMembers(ctx, group.ID, []int{user.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	subscriber := &middleware.User{ID: strconv.Itoa(user.ID), Role: models.RoleUser, Roles: []string{models.RoleAdmin, models.RoleUser}}
+	conn := subscribeAs(t, subscriber, "subscription { userChanged { id } }")
+
+	// A group change that leaves the subscriber's roles alone keeps it open
+	events.Publish(events.Event{Type: events.GroupsChanged})
+	events.Publish(userChanged(user.ID+1, models.RoleUser))
+	if m := read(t, conn); m.Type != "next" {
+		t.Fatalf("got %+v, want the change to another user", m)
+	}
+
+	if _, err := database.DB.Exec(ctx, `DELETE FROM group_members WHERE group_id = $1`, group.ID); err != nil {
+		t.Fatal(err)
+	}
+	events.Publish(events.Event{Type: events.GroupsChanged})
+	if m := read(t, conn); m.Type != "complete" {
+		t.Errorf("got %+v, want the subscription to complete", m)
+	}
+}
+
+func TestSubscribeRequiresAuthentication(t *testing.T) {
+	conn := subscribeAs(t, nil, "subscription { userDeleted }")
+
+	if m := read(t, conn); !strings.Contains(string(m.Payload), "access denied") {
+		t.Errorf("got %+v, want an access denied error", m)
+	}
+}
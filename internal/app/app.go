@@ -0,0 +1,365 @@
+// Package app wires the service together and owns its lifecycle: the public
+// HTTP server, the admin listener, background workers and the database pool.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"user-management-service/graph"
+	"user-management-service/internal/auth"
+	"user-management-service/internal/bulk"
+	"user-management-service/internal/config"
+	"user-management-service/internal/database"
+	"user-management-service/internal/email"
+	"user-management-service/internal/events"
+	"user-management-service/internal/health"
+	"user-management-service/internal/jobs"
+	"user-management-service/internal/loaders"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/metrics"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/oidc"
+	"user-management-service/internal/repository"
+	"user-management-service/internal/router"
+	"user-management-service/internal/scim"
+	"user-management-service/internal/tracing"
+	"user-management-service/migrations"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gorilla/websocket"
+	"github.com/rs/cors"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Worker is a background task that runs until its context is cancelled
+type Worker struct {
+	Name string
+	Run  func(ctx context.Context)
+}
+
+// App is the running service
+type App struct {
+	cfg      *config.Config
+	server   *http.Server
+	admin    *http.Server
+	adminMux *http.ServeMux
+	health   *health.Registry
+
+	scheduler *jobs.Scheduler
+
+	// shutdownTracing flushes buffered spans
+	shutdownTracing func(context.Context) error
+
+	workers       []Worker
+	workersCancel context.CancelFunc
+	workersDone   sync.WaitGroup
+
+	// serveErr receives listener failures after Start
+	serveErr chan error
+}
+
+// New connects to dependencies and builds the HTTP handlers
+func New(cfg *config.Config) (*App, error) {
+	shutdownTracing, err := tracing.Init(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	email.Init(cfg)
+	auth.SetJWTSecret(cfg.JWTSecret)
+	auth.AllowMockGoogleToken(cfg.Environment == config.Development)
+
+	// Connect to Database (retries until connected or DB_CONNECT_TIMEOUT)
+	if err := database.Connect(cfg); err != nil {
+		shutdownTracing(context.Background())
+		return nil, err
+	}
+	repository.SetTimeoutPolicy(repository.TimeoutPolicy{
+		Read:  cfg.DBReadTimeout,
+		Write: cfg.DBWriteTimeout,
+		Bulk:  cfg.DBBulkTimeout,
+	})
+
+	a := &App{
+		cfg:      cfg,
+		adminMux: http.NewServeMux(),
+		health:   health.NewRegistry(cfg.HealthCacheTTL),
+		serveErr: make(chan error, 2),
+
+		shutdownTracing: shutdownTracing,
+	}
+	a.registerHealthChecks()
+	a.AddWorker(Worker{Name: "events", Run: events.Listen})
+	a.AddWorker(Worker{Name: "imports", Run: bulk.RunJobs})
+
+	a.scheduler, err = jobs.NewScheduler(jobs.Maintenance(cfg))
+	if err != nil {
+		database.CloseDB()
+		return nil, err
+	}
+	if cfg.JobsEnabled {
+		a.AddWorker(Worker{Name: "scheduler", Run: a.scheduler.Run})
+	}
+
+	h, err := a.buildHandler()
+	if err != nil {
+		database.CloseDB()
+		return nil, err
+	}
+
+	a.server = &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	if cfg.AdminAddr != "" {
+		a.adminMux.Handle("/metrics", metrics.Handler())
+		a.adminMux.HandleFunc("/debug/pprof/", pprof.Index)
+		a.adminMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		a.adminMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		a.adminMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		a.adminMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+		a.admin = &http.Server{
+			Addr:              cfg.AdminAddr,
+			Handler:           a.adminMux,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			// CPU profiles and traces stream for their whole duration (30s by default)
+			WriteTimeout: 2 * time.Minute,
+		}
+	}
+
+	return a, nil
+}
+
+func (a *App) registerHealthChecks() {
+	a.health.Register(health.Check{Name: "postgres", Func: health.PostgresPing})
+	a.health.Register(health.Check{Name: "postgres_pool", Func: health.PoolSaturation(0.95)})
+	if a.cfg.DatabaseReplicaURL != "" {
+		a.health.Register(health.Check{Name: "postgres_replica", Optional: true, Func: health.ReplicaPing})
+	}
+	a.health.Register(health.Check{Name: "migrations", Func: health.MigrationVersion(migrations.Latest())})
+	if a.cfg.HealthCheckSMTP && a.cfg.SMTPEmail != "" {
+		a.health.Register(health.Check{
+			Name:     "smtp",
+			Optional: true,
+			Func:     health.TCPReachable(net.JoinHostPort(a.cfg.SMTPHost, a.cfg.SMTPPort)),
+		})
+	}
+}
+
+func (a *App) buildHandler() (http.Handler, error) {
+	r := router.SetupRouter()
+	r.Use(tracing.RouteMiddleware, metrics.HTTPMiddleware)
+
+	// Probes
+	r.HandleFunc("/livez", a.health.Livez).Methods("GET")
+	r.HandleFunc("/readyz", a.health.Readyz).Methods("GET")
+
+	// Metrics move to the admin listener when one is configured
+	if a.cfg.AdminAddr == "" {
+		r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	}
+
+	// GraphQL Handler
+	srv, err := a.graphQLServer()
+	if err != nil {
+		return nil, err
+	}
+	r.Handle("/graphql", loaders.Middleware(srv))
+	if a.cfg.Environment == config.Development {
+		r.Handle("/playground", playground.Handler("GraphQL playground", "/graphql"))
+	}
+
+	// OIDC provider endpoints for internal apps delegating login
+	signingKey, err := oidc.LoadSigningKey(a.cfg.OIDCSigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OIDC signing key: %v", err)
+	}
+	oidc.NewProvider(a.cfg.OIDCIssuer, signingKey).RegisterRoutes(r)
+
+	// SCIM provisioning for customers' identity providers
+	scim.RegisterRoutes(r)
+
+	// Add CORS middleware
+	c := cors.New(cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{logging.RequestIDHeader, "ETag"},
+		AllowCredentials: true,
+	})
+
+	h := middleware.AuthMiddleware()(r)
+	return tracing.Handler(logging.Middleware(c.Handler(h))), nil
+}
+
+// graphQLServer builds the GraphQL handler. Introspection is only enabled in
+// development; in production clients are expected to ship their queries.
+func (a *App) graphQLServer() (*handler.Server, error) {
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers:  &graph.Resolver{Config: a.cfg, Scheduler: a.scheduler},
+		Directives: graph.DirectiveRoot{BlockImpersonation: graph.BlockImpersonation},
+	}))
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              graph.WebsocketInit,
+		// Credentials travel in the connection payload, not cookies, so
+		// cross-origin clients are as safe as they are over HTTP with CORS
+		Upgrader: websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{MaxUploadSize: bulk.MaxUploadSize})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+
+	if a.cfg.Environment == config.Development {
+		srv.Use(extension.Introspection{})
+	}
+	// The allow-list must see operations before APQ can cache them
+	var operations map[string]bool
+	if a.cfg.GraphQLAllowListFile != "" {
+		allowList, err := graph.LoadAllowList(a.cfg.GraphQLAllowListFile)
+		if err != nil {
+			return nil, err
+		}
+		srv.Use(allowList)
+		operations = allowList.OperationNames()
+	}
+	srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](1000)})
+	srv.Use(&graph.Limits{MaxComplexity: a.cfg.GraphQLMaxComplexity, MaxDepth: a.cfg.GraphQLMaxDepth})
+
+	srv.AroundOperations(graph.RequireScopes)
+	srv.Use(loaders.GraphQLExtension{})
+	srv.Use(metrics.GraphQLExtension{Operations: operations})
+	srv.Use(tracing.GraphQLExtension{})
+	return srv, nil
+}
+
+// AddWorker registers a background task. Workers start with Start and are
+// stopped, after the HTTP server has drained, by Shutdown.
+func (a *App) AddWorker(w Worker) {
+	a.workers = append(a.workers, w)
+}
+
+// Start begins serving and launches background workers. It does not block.
+func (a *App) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.workersCancel = cancel
+	for _, w := range a.workers {
+		a.workersDone.Add(1)
+		go func(w Worker) {
+			defer a.workersDone.Done()
+			slog.Info("Worker started", "worker", w.Name)
+			w.Run(ctx)
+			slog.Info("Worker stopped", "worker", w.Name)
+		}(w)
+	}
+
+	if a.admin != nil {
+		go func() {
+			slog.Info("Admin listener (metrics, pprof) started", "addr", a.cfg.AdminAddr)
+			if err := a.admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				a.serveErr <- fmt.Errorf("admin listener: %v", err)
+			}
+		}()
+	}
+
+	go func() {
+		slog.Info("Server listening", "port", a.cfg.Port)
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.serveErr <- fmt.Errorf("server: %v", err)
+		}
+	}()
+}
+
+// Shutdown stops the service in order: fail readiness so load balancers
+// stop routing here, stop accepting requests and drain in-flight ones, stop
+// background workers, close the admin listener, close the database pool,
+// then flush buffered spans. ctx bounds the whole sequence.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	a.health.SetShuttingDown()
+	select {
+	case <-time.After(a.cfg.ReadinessDrainDelay):
+	case <-ctx.Done():
+	}
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("draining requests: %v", err))
+	}
+
+	if a.workersCancel != nil {
+		a.workersCancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		a.workersDone.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, errors.New("background workers did not stop before the deadline"))
+	}
+
+	if a.admin != nil {
+		if err := a.admin.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("admin listener: %v", err))
+		}
+	}
+
+	database.CloseDB()
+
+	if err := a.shutdownTracing(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("flushing traces: %v", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Run starts the service and blocks until SIGINT/SIGTERM or a listener
+// failure, then shuts down within the configured drain deadline.
+func (a *App) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a.Start()
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received, draining requests...")
+	case serveErr = <-a.serveErr:
+		slog.Error("Listener failed", "err", serveErr)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := a.Shutdown(shutdownCtx); err != nil {
+		return errors.Join(serveErr, err)
+	}
+
+	slog.Info("Shutdown complete")
+	return serveErr
+}
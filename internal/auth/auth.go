@@ -12,24 +12,110 @@ import (
 	"google.golang.org/api/idtoken"
 )
 
-var jwtKey = []byte("your_secret_key") // In production, use an environment variable
+// jwtKey signs session tokens; it is set from JWT_SECRET at startup
+var jwtKey = []byte("your_secret_key")
+
+// SetJWTSecret sets the HMAC secret used to sign and verify session tokens
+func SetJWTSecret(secret string) {
+	jwtKey = []byte(secret)
+}
+
+// mockGoogleToken is accepted by VerifyGoogleToken in place of a real ID
+// token, for local testing only
+const mockGoogleToken = "mock_token"
+
+// allowMockGoogleToken is set at startup in the development environment
+var allowMockGoogleToken bool
+
+// AllowMockGoogleToken makes VerifyGoogleToken accept "mock_token" as
+// test@gmail.com. Anyone could sign in as that account with it, so it is
+// only enabled in development.
+func AllowMockGoogleToken(allow bool) {
+	allowMockGoogleToken = allow
+}
 
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// Act identifies the real user behind an impersonation token (RFC 8693)
+	Act *Actor `json:"act,omitempty"`
+	// Scope lists the scopes granted to the OAuth client an access token
+	// was issued to
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT creates a new JWT token for a user
-func GenerateJWT(userID, email, role string) (string, error) {
-	expirationTime := time.Now().Add(15 * time.Minute) // Token valid for 15 minutes
-	claims := &Claims{
+// Actor is the party acting on behalf of the token subject
+type Actor struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+}
+
+// ImpersonationTTL is how long an impersonation token is valid
+const ImpersonationTTL = 10 * time.Minute
+
+// SessionTTL is how long a login session token is valid
+const SessionTTL = 15 * time.Minute
+
+// AccessTokenAudience is the audience of OAuth access tokens. They are only
+// accepted by the userinfo endpoint, not by the API.
+const AccessTokenAudience = "userinfo"
+
+// IsAccessToken reports whether the token was issued to an OAuth client
+func (c *Claims) IsAccessToken() bool {
+	for _, aud := range c.Audience {
+		if aud == AccessTokenAudience {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateJWT creates a new JWT token for a user's session
+func GenerateJWT(sessionID, userID, email, role string, expiresAt time.Time) (string, error) {
+	return signSessionJWT(&Claims{
+		UserID: userID,
+		Email:  email,
+		Role:   role,
+	}, sessionID, expiresAt)
+}
+
+// GenerateAccessJWT creates an OAuth access token for the userinfo endpoint
+// that records the scopes granted to the client
+func GenerateAccessJWT(sessionID, userID, email, role, scope string, expiresAt time.Time) (string, error) {
+	return signSessionJWT(&Claims{
 		UserID: userID,
 		Email:  email,
 		Role:   role,
+		Scope:  scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience: jwt.ClaimStrings{AccessTokenAudience},
+		},
+	}, sessionID, expiresAt)
+}
+
+func signSessionJWT(claims *Claims, sessionID string, expiresAt time.Time) (string, error) {
+	claims.ID = sessionID
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtKey)
+}
+
+// GenerateImpersonationJWT creates a short-lived token for the subject user
+// that also names the acting user. sessionID becomes the token ID so the
+// session can be ended before the token expires.
+func GenerateImpersonationJWT(sessionID string, subjectID, subjectEmail, subjectRole string, actor Actor, expiresAt time.Time) (string, error) {
+	claims := &Claims{
+		UserID: subjectID,
+		Email:  subjectEmail,
+		Role:   subjectRole,
+		Act:    &actor,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	}
 
@@ -68,22 +154,34 @@ func GenerateOTP() (string, error) {
 	return otp, nil
 }
 
-// VerifyGoogleToken verifies the Google ID token and returns the user's email
-func VerifyGoogleToken(ctx context.Context, idToken string, clientID string) (string, error) {
-	// For development/demo purposes, we'll allow a mock token if clientID is not set or for specific test tokens
-	if idToken == "mock_token" {
-		return "test@gmail.com", nil
+// GoogleIdentity is the subset of a verified Google ID token we rely on
+type GoogleIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// VerifyGoogleToken verifies the Google ID token and returns the account's stable subject and email
+func VerifyGoogleToken(ctx context.Context, idToken string, clientID string) (*GoogleIdentity, error) {
+	if allowMockGoogleToken && idToken == mockGoogleToken {
+		return &GoogleIdentity{Subject: "mock-google-subject", Email: "test@gmail.com", EmailVerified: true}, nil
 	}
 
 	payload, err := idtoken.Validate(ctx, idToken, clientID)
 	if err != nil {
-		return "", fmt.Errorf("failed to validate id token: %v", err)
+		return nil, fmt.Errorf("failed to validate id token: %v", err)
 	}
 
 	email, ok := payload.Claims["email"].(string)
 	if !ok {
-		return "", errors.New("email not found in google token payload")
+		return nil, errors.New("email not found in google token payload")
+	}
+
+	if payload.Subject == "" {
+		return nil, errors.New("subject not found in google token payload")
 	}
 
-	return email, nil
+	verified, _ := payload.Claims["email_verified"].(bool)
+
+	return &GoogleIdentity{Subject: payload.Subject, Email: email, EmailVerified: verified}, nil
 }
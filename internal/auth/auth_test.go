@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMockGoogleTokenOnlyInDevelopment(t *testing.T) {
+	t.Cleanup(func() { AllowMockGoogleToken(false) })
+
+	if _, err := VerifyGoogleToken(context.Background(), mockGoogleToken, "client-id"); err == nil {
+		t.Error("expected the mock token to be rejected outside development")
+	}
+
+	AllowMockGoogleToken(true)
+	identity, err := VerifyGoogleToken(context.Background(), mockGoogleToken, "client-id")
+	if err != nil || identity.Email != "test@gmail.com" {
+		t.Errorf("expected the mock identity in development, got %+v (%v)", identity, err)
+	}
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"user-management-service/internal/database"
+	emailpkg "user-management-service/internal/email"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// EmailChangeTTL is how long the token confirming an email change is valid
+const EmailChangeTTL = time.Hour
+
+// ErrInvalidEmail is returned by RequestEmailChange for a malformed address
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// ErrEmailUnchanged is returned by RequestEmailChange when the new email is
+// the current one
+var ErrEmailUnchanged = errors.New("the new email is the same as the current one")
+
+// RequestEmailChange starts changing a user's email to newEmail and returns
+// the user. Nothing changes until the token mailed to newEmail is passed to
+// ConfirmEmailChange; the current address is told about the request.
+func RequestEmailChange(ctx context.Context, user *models.User, newEmail string) (*models.User, error) {
+	newEmail = strings.TrimSpace(newEmail)
+	if addr, err := mail.ParseAddress(newEmail); err != nil || addr.Address != newEmail {
+		return nil, ErrInvalidEmail
+	}
+	if newEmail == user.Email {
+		return nil, ErrEmailUnchanged
+	}
+
+	taken, err := repository.GetUsersByEmails(database.WithPrimary(ctx), []string{newEmail})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up email: %v", err)
+	}
+	if len(taken) > 0 {
+		return nil, repository.ErrEmailTaken
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(raw)
+
+	updated, err := repository.RequestEmailChange(ctx, user.ID, newEmail, hashEmailChangeToken(token), time.Now().Add(EmailChangeTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	// Without the token the change cannot be confirmed, so this must not fail silently
+	if err := emailpkg.SendEmailChangeConfirmation(ctx, newEmail, token, EmailChangeTTL); err != nil {
+		return nil, fmt.Errorf("failed to send confirmation email: %v", err)
+	}
+	if err := emailpkg.SendEmailChangeNotice(ctx, user.Email, newEmail); err != nil {
+		logging.FromContext(ctx).Error("Failed to notify the current email of a change", "user_id", user.ID, "err", err)
+	}
+	return updated, nil
+}
+
+// ConfirmEmailChange commits the email change the token was mailed for and
+// returns the user with their previous email. Every session of the user is
+// revoked, so they sign in again with the new address.
+func ConfirmEmailChange(ctx context.Context, token string) (*models.User, string, error) {
+	return repository.ConfirmEmailChange(ctx, hashEmailChangeToken(strings.TrimSpace(token)))
+}
+
+// hashEmailChangeToken returns the stored representation of a token
+func hashEmailChangeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
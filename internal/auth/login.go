@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/metrics"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// EmailSubject normalizes an address into the subject used for email identities
+func EmailSubject(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ConsumeOTP validates the latest OTP for an email and marks it as used
+func ConsumeOTP(ctx context.Context, email, otp string) error {
+	// The OTP was issued moments ago; a lagging replica may not have it yet
+	latestOtp, err := repository.GetLatestOTP(database.WithPrimary(ctx), email)
+	if err != nil {
+		return fmt.Errorf("failed to check OTP: %v", err)
+	}
+
+	if latestOtp == nil {
+		metrics.OTPFailed("not_found")
+		return errors.New("no OTP request found for this email")
+	}
+
+	if latestOtp.IsUsed {
+		metrics.OTPFailed("used")
+		return errors.New("OTP has already been used")
+	}
+
+	if time.Now().After(latestOtp.ExpiresAt) {
+		metrics.OTPFailed("expired")
+		return errors.New("OTP has expired")
+	}
+
+	if latestOtp.AttemptCount >= 3 {
+		metrics.OTPFailed("attempts_exceeded")
+		return errors.New("maximum verification attempts exceeded")
+	}
+
+	if latestOtp.OTP != otp {
+		repository.IncrementOTPAttempts(ctx, latestOtp.ID)
+		metrics.OTPFailed("invalid")
+		return errors.New("invalid OTP")
+	}
+
+	if err := repository.MarkOTPAsUsed(ctx, latestOtp.ID); err != nil {
+		return fmt.Errorf("failed to finalize OTP: %v", err)
+	}
+	metrics.OTPVerified()
+	return nil
+}
+
+// SignInIdentity resolves the user behind a verified provider identity.
+// Users are matched by provider subject first. An existing account with the
+// same email is only linked when matchEmail is set (the provider has proven
+// ownership of the address); otherwise newUser is created with the identity.
+func SignInIdentity(ctx context.Context, identity *models.Identity, newUser *models.User, matchEmail bool) (*models.User, error) {
+	linked, err := repository.GetIdentity(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up identity: %v", err)
+	}
+	if linked != nil {
+		user, err := repository.GetUserByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("linked account no longer exists")
+		}
+		return user, nil
+	}
+
+	if existing, err := repository.GetUserByEmail(ctx, identity.Email); err == nil {
+		if !matchEmail {
+			return nil, errors.New("an account with this email already exists: sign in and link this login method from your account")
+		}
+		identity.UserID = existing.ID
+		if err := repository.LinkIdentity(ctx, identity); err != nil {
+			return nil, fmt.Errorf("failed to link identity: %v", err)
+		}
+		return existing, nil
+	}
+
+	if err := repository.CreateUserWithIdentity(ctx, newUser, identity); err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+	return newUser, nil
+}
+
+// SignInWithEmail resolves (or creates) the user for an address whose OTP has been verified
+func SignInWithEmail(ctx context.Context, email string, role string) (*models.User, error) {
+	return SignInIdentity(
+		ctx,
+		&models.Identity{Provider: models.ProviderEmail, Subject: EmailSubject(email), Email: email},
+		&models.User{
+			Name:  "OTP User",
+			Email: email,
+			Role:  role,
+		},
+		true,
+	)
+}
+
+// IssueSession records a new login session for a user and returns its JWT.
+// It fails with repository.ErrUserDeactivated for deactivated users.
+func IssueSession(ctx context.Context, user *models.User) (string, error) {
+	session, err := createSession(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	return GenerateJWT(session.ID, strconv.Itoa(user.ID), user.Email, user.Role, session.ExpiresAt)
+}
+
+// IssueAccessToken is IssueSession for an OAuth client. The token records
+// the scopes the user granted it and is only accepted by the userinfo
+// endpoint.
+func IssueAccessToken(ctx context.Context, user *models.User, scope string) (string, error) {
+	session, err := createSession(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	return GenerateAccessJWT(session.ID, strconv.Itoa(user.ID), user.Email, user.Role, scope, session.ExpiresAt)
+}
+
+func createSession(ctx context.Context, user *models.User) (*models.Session, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:        hex.EncodeToString(id),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(SessionTTL),
+	}
+	if err := repository.CreateSession(ctx, session); err != nil {
+		if errors.Is(err, repository.ErrUserDeactivated) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+	return session, nil
+}
@@ -0,0 +1,294 @@
+// Package bulk imports users from and exports them to CSV and NDJSON files.
+// Imports are validated row by row before anything is written, so one bad
+// row does not abort the whole file; it is reported instead.
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"path"
+	"strings"
+
+	"user-management-service/internal/models"
+)
+
+// Format is a file format for imports and exports
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Mode decides what an import does with users that already exist, matched
+// by email: INSERT reports them as failed rows, UPSERT updates them.
+type Mode string
+
+const (
+	ModeInsert Mode = "INSERT"
+	ModeUpsert Mode = "UPSERT"
+)
+
+// Limits on a single import
+const (
+	MaxUploadSize = 64 << 20
+	MaxRows       = 100_000
+)
+
+// maxReportedErrors caps the row errors kept in a report, so a file that is
+// entirely wrong does not produce a report as large as itself
+const maxReportedErrors = 1000
+
+// ParseFormat accepts "csv" and "ndjson", in any case. An empty name is CSV.
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("unsupported format %q: expected csv or ndjson", name)
+}
+
+// DetectFormat guesses an upload's format from its file name and content
+// type, defaulting to CSV
+func DetectFormat(filename, contentType string) Format {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	if strings.HasPrefix(contentType, "application/x-ndjson") || strings.HasPrefix(contentType, "application/jsonl") {
+		return FormatNDJSON
+	}
+	return FormatCSV
+}
+
+// ParseMode accepts "insert" and "upsert", in any case. An empty name is INSERT.
+func ParseMode(name string) (Mode, error) {
+	switch Mode(strings.ToUpper(name)) {
+	case "", ModeInsert:
+		return ModeInsert, nil
+	case ModeUpsert:
+		return ModeUpsert, nil
+	}
+	return "", fmt.Errorf("unsupported mode %q: expected insert or upsert", name)
+}
+
+// Row is a valid user read from an import file
+type Row struct {
+	Line int
+	User models.User
+}
+
+// RowError explains why a row was not imported. Line is the row's line in
+// the file, counting a CSV header as line 1.
+type RowError struct {
+	Line    int    `json:"line"`
+	Email   string `json:"email,omitempty"`
+	Message string `json:"message"`
+}
+
+// Report summarises an import. For a dry run the counts are what the import
+// would have done.
+type Report struct {
+	DryRun    bool `json:"dry_run"`
+	Total     int  `json:"total"`
+	Inserted  int  `json:"inserted"`
+	Updated   int  `json:"updated"`
+	Unchanged int  `json:"unchanged"`
+	Failed    int  `json:"failed"`
+	// Errors holds the first failed rows; ErrorsTruncated is set when there were more
+	Errors          []RowError `json:"errors"`
+	ErrorsTruncated bool       `json:"errors_truncated"`
+}
+
+func (r *Report) fail(line int, email, message string) {
+	r.Failed++
+	if len(r.Errors) >= maxReportedErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	r.Errors = append(r.Errors, RowError{Line: line, Email: email, Message: message})
+}
+
+// Parse reads and validates every row of an import file. It returns the
+// valid rows and a report counting the rest; an error means the file as a
+// whole is unusable.
+func Parse(r io.Reader, format Format) ([]Row, *Report, error) {
+	p := &parser{report: &Report{Errors: []RowError{}}, seen: make(map[string]int)}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		err = p.parseCSV(r)
+	case FormatNDJSON:
+		err = p.parseNDJSON(r)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.rows, p.report, nil
+}
+
+type parser struct {
+	rows   []Row
+	report *Report
+	// seen maps each email to the line it was first seen on
+	seen map[string]int
+}
+
+// count counts a row, failing once the file has too many
+func (p *parser) count() error {
+	p.report.Total++
+	if p.report.Total > MaxRows {
+		return fmt.Errorf("file has more than %d rows", MaxRows)
+	}
+	return nil
+}
+
+// add validates one row and keeps it if it is valid
+func (p *parser) add(line int, user models.User) error {
+	if err := p.count(); err != nil {
+		return err
+	}
+
+	user.Name = strings.TrimSpace(user.Name)
+	user.Email = strings.TrimSpace(user.Email)
+	user.Role = strings.ToUpper(strings.TrimSpace(user.Role))
+
+	if msg := validate(&user); msg != "" {
+		p.report.fail(line, user.Email, msg)
+		return nil
+	}
+	if first, ok := p.seen[user.Email]; ok {
+		p.report.fail(line, user.Email, fmt.Sprintf("duplicate of line %d", first))
+		return nil
+	}
+	p.seen[user.Email] = line
+	p.rows = append(p.rows, Row{Line: line, User: user})
+	return nil
+}
+
+// validate checks a row the way user creation does, returning a message
+// for the report, or "" when the row is valid
+func validate(user *models.User) string {
+	if user.Name == "" {
+		return "name is required"
+	}
+	if user.Email == "" {
+		return "email is required"
+	}
+	if addr, err := mail.ParseAddress(user.Email); err != nil || addr.Address != user.Email {
+		return "email is not a valid address"
+	}
+	if user.Role != "" && !models.IsValidRole(user.Role) {
+		return fmt.Sprintf("unknown role %q", user.Role)
+	}
+	return ""
+}
+
+// csvColumns are the columns an import understands. id is accepted so an
+// export can be imported again, but ignored: users are matched by email.
+var csvColumns = map[string]bool{"id": true, "name": true, "email": true, "role": true}
+
+func (p *parser) parseCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return errors.New("file is empty")
+	}
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !csvColumns[name] {
+			return fmt.Errorf("unknown column %q: expected name, email and optionally role", name)
+		}
+		if _, ok := columns[name]; ok {
+			return fmt.Errorf("column %q appears twice", name)
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("missing required column %q", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading CSV: %v", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			if err := p.count(); err != nil {
+				return err
+			}
+			p.report.fail(line, "", fmt.Sprintf("expected %d fields, got %d", len(header), len(record)))
+			continue
+		}
+
+		user := models.User{Name: field(record, "name"), Email: field(record, "email"), Role: field(record, "role")}
+		if err := p.add(line, user); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseNDJSON(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading NDJSON: %v", err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			continue
+		}
+
+		var user models.User
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if decodeErr := decoder.Decode(&user); decodeErr != nil {
+			if err := p.count(); err != nil {
+				return err
+			}
+			p.report.fail(line, "", fmt.Sprintf("invalid JSON: %v", decodeErr))
+		} else if err := p.add(line, user); err != nil {
+			return err
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
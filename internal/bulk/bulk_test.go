@@ -0,0 +1,72 @@
+package bulk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCSVReportsInvalidRows(t *testing.T) {
+	file := "Email,Name,Role\n" +
+		"ada@example.com,Ada,admin\n" +
+		"grace@example.com,,\n" +
+		"not-an-email,Bob,\n" +
+		"linus@example.com,Linus,OWNER\n" +
+		"ada@example.com,Ada Again,\n" +
+		"short\n" +
+		"ken@example.com, Ken ,\n"
+
+	rows, report, err := Parse(strings.NewReader(file), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 || rows[0].User.Role != "ADMIN" || rows[1].User.Name != "Ken" || rows[1].Line != 8 {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+	if report.Total != 7 || report.Failed != 5 {
+		t.Errorf("expected 7 rows with 5 failed, got %+v", report)
+	}
+
+	want := map[int]string{3: "name is required", 4: "email is not a valid address", 5: `unknown role "OWNER"`, 6: "duplicate of line 2", 7: "expected 3 fields, got 1"}
+	for _, e := range report.Errors {
+		if want[e.Line] != e.Message {
+			t.Errorf("line %d: expected %q, got %q", e.Line, want[e.Line], e.Message)
+		}
+	}
+}
+
+func TestParseCSVRejectsBadHeader(t *testing.T) {
+	for _, header := range []string{"name,role\n", "name,email,password\n", "name,email,email\n", ""} {
+		if _, _, err := Parse(strings.NewReader(header), FormatCSV); err == nil {
+			t.Errorf("expected header %q to be rejected", header)
+		}
+	}
+}
+
+func TestParseNDJSON(t *testing.T) {
+	file := `{"id": 7, "name": "Ada", "email": "ada@example.com"}` + "\n" +
+		"\n" +
+		`{"name": "Bob", "email": "bob@example.com", "password": "x"}` + "\n" +
+		`{"name": "Eve", "email": "eve@example.com", "role": "SUPPORT"}`
+
+	rows, report, err := Parse(strings.NewReader(file), FormatNDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1].Line != 4 || rows[1].User.Role != "SUPPORT" {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+	if report.Total != 3 || report.Failed != 1 || report.Errors[0].Line != 3 {
+		t.Errorf("expected line 3 to fail, got %+v", report)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields(" email, NAME ,email")
+	if err != nil || strings.Join(fields, ",") != "email,name" {
+		t.Errorf("expected email,name, got %v (%v)", fields, err)
+	}
+	if _, err := ParseFields("email,password"); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+}
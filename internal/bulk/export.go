@@ -0,0 +1,134 @@
+package bulk
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// ExportFields are the fields an export can include, in their default order
+var ExportFields = []string{"id", "name", "email", "role"}
+
+// flushEvery is how many rows an export writes between flushes
+const flushEvery = 500
+
+// ParseFields reads a comma separated field list. An empty list selects
+// every field.
+func ParseFields(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return ExportFields, nil
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(list, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if !isExportField(f) {
+			return nil, fmt.Errorf("unknown field %q: expected some of %s", f, strings.Join(ExportFields, ", "))
+		}
+		if !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+func isExportField(f string) bool {
+	for _, e := range ExportFields {
+		if e == f {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldValue(user *models.User, field string) string {
+	switch field {
+	case "id":
+		return strconv.Itoa(user.ID)
+	case "name":
+		return user.Name
+	case "email":
+		return user.Email
+	default:
+		return user.Role
+	}
+}
+
+// Export streams the users matching filter to w, one per CSV row or NDJSON
+// line, with only the given fields. flush, if set, is called every few
+// hundred rows after buffered output has been written to w, so a client
+// sees progress and a slow export keeps its connection alive.
+func Export(ctx context.Context, w io.Writer, format Format, fields []string, filter repository.UserFilter, flush func() error) error {
+	bw := bufio.NewWriter(w)
+	rows := 0
+	flushed := func() error {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if flush != nil {
+			return flush()
+		}
+		return nil
+	}
+
+	var write func(*models.User) error
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(bw)
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+		record := make([]string, len(fields))
+		write = func(user *models.User) error {
+			for i, f := range fields {
+				record[i] = fieldValue(user, f)
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+			// csv.Writer buffers too; hand rows on to bw as they are written
+			cw.Flush()
+			return cw.Error()
+		}
+	case FormatNDJSON:
+		encoder := json.NewEncoder(bw)
+		write = func(user *models.User) error {
+			object := make(map[string]interface{}, len(fields))
+			for _, f := range fields {
+				if f == "id" {
+					object[f] = user.ID
+				} else {
+					object[f] = fieldValue(user, f)
+				}
+			}
+			return encoder.Encode(object)
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+
+	err := repository.StreamUsers(ctx, filter, func(user *models.User) error {
+		if err := write(user); err != nil {
+			return err
+		}
+		rows++
+		if rows%flushEvery == 0 {
+			return flushed()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flushed()
+}
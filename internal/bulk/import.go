@@ -0,0 +1,77 @@
+package bulk
+
+import (
+	"context"
+	"fmt"
+
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// batchSize is how many rows are written per transaction. A failed import
+// keeps the batches committed before the failure.
+const batchSize = 1000
+
+// Import writes rows in batches, adding the outcome to report. Role
+// changes are audited as made by actorID. progress, if set, is called
+// after each batch with the number of rows written.
+func Import(ctx context.Context, rows []Row, mode Mode, report *Report, actorID *int, progress func(done int)) error {
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+
+		users := make([]*models.User, len(batch))
+		lines := make(map[string]int, len(batch))
+		for i := range batch {
+			users[i] = &batch[i].User
+			lines[batch[i].User.Email] = batch[i].Line
+		}
+
+		result, err := repository.ImportUsers(ctx, users, mode == ModeUpsert, actorID)
+		if err != nil {
+			return fmt.Errorf("importing rows %d to %d: %v", batch[0].Line, batch[len(batch)-1].Line, err)
+		}
+		report.Inserted += result.Inserted
+		report.Updated += result.Updated
+		report.Unchanged += result.Unchanged
+		for _, email := range result.Existing {
+			report.fail(lines[email], email, "a user with this email already exists")
+		}
+
+		if progress != nil {
+			progress(start + len(batch))
+		}
+	}
+	return nil
+}
+
+// Plan fills in report with what Import would do, without writing anything
+func Plan(ctx context.Context, rows []Row, mode Mode, report *Report) error {
+	report.DryRun = true
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+
+		emails := make([]string, len(batch))
+		for i, row := range batch {
+			emails[i] = row.User.Email
+		}
+		existing, err := repository.GetUsersByEmails(ctx, emails)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range batch {
+			user, ok := existing[row.User.Email]
+			switch {
+			case !ok:
+				report.Inserted++
+			case mode != ModeUpsert:
+				report.fail(row.Line, row.User.Email, "a user with this email already exists")
+			case user.Name != row.User.Name || (row.User.Role != "" && user.Role != row.User.Role):
+				report.Updated++
+			default:
+				report.Unchanged++
+			}
+		}
+	}
+	return nil
+}
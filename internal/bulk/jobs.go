@@ -0,0 +1,230 @@
+package bulk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// Job statuses
+const (
+	StatusPending   = "PENDING"
+	StatusRunning   = "RUNNING"
+	StatusSucceeded = "SUCCEEDED"
+	StatusFailed    = "FAILED"
+)
+
+// AsyncThreshold is the number of valid rows above which an import runs in
+// the background instead of within the request
+const AsyncThreshold = 1000
+
+// jobRetention is how long finished jobs can still be looked up
+const jobRetention = time.Hour
+
+// Job is an import and its progress. Jobs live in the memory of the process
+// that runs them: they are lost on restart, and behind a load balancer a
+// job can only be looked up on the replica that started it.
+type Job struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Mode   Mode   `json:"mode"`
+	// Total is the number of valid rows to write and Processed how many
+	// have been written so far
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Report     *Report    `json:"report"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	jobsMu sync.Mutex
+	jobs   = make(map[string]*Job)
+
+	// runCtx is the context of RunJobs, while it runs; background imports
+	// are cancelled with it. running tracks them so RunJobs can wait.
+	runCtx  context.Context
+	running sync.WaitGroup
+)
+
+// ErrJobsStopped is returned by Start for an import too large to run within
+// the request when background imports are not running, as during shutdown
+var ErrJobsStopped = errors.New("background imports are not running")
+
+// RunJobs lets Start run imports in the background until ctx is cancelled,
+// then cancels those still running and waits for them to record their
+// outcome. The service runs it as a worker, so shutdown waits for imports
+// before closing the database.
+func RunJobs(ctx context.Context) {
+	jobsMu.Lock()
+	runCtx = ctx
+	jobsMu.Unlock()
+
+	<-ctx.Done()
+
+	jobsMu.Lock()
+	runCtx = nil
+	jobsMu.Unlock()
+	running.Wait()
+}
+
+// GetJob returns a snapshot of a job, or nil if there is no such job
+func GetJob(id string) *Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	job, ok := jobs[id]
+	if !ok {
+		return nil
+	}
+	return job.snapshot()
+}
+
+// snapshot copies the job so it can be read while the import carries on.
+// The caller must hold jobsMu.
+func (j *Job) snapshot() *Job {
+	c := *j
+	c.Report = j.Report.snapshot()
+	return &c
+}
+
+// Start imports rows parsed into report, on behalf of actorID. Dry runs and
+// imports of up to AsyncThreshold rows finish before Start returns; larger
+// imports continue in the background, to be followed with GetJob, and fail
+// with ErrJobsStopped unless RunJobs is running.
+func Start(ctx context.Context, rows []Row, mode Mode, report *Report, dryRun bool, actorID *int) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{ID: id, Status: StatusPending, Mode: mode, Total: len(rows), Report: report, CreatedAt: time.Now()}
+	async := !dryRun && len(rows) > AsyncThreshold
+
+	jobsMu.Lock()
+	if async && runCtx == nil {
+		jobsMu.Unlock()
+		return nil, ErrJobsStopped
+	}
+	pruneJobs()
+	jobs[id] = job
+	if async {
+		running.Add(1)
+	}
+	stopped := runCtx
+	jobsMu.Unlock()
+
+	if !async {
+		run(ctx, job, rows, dryRun, actorID)
+		return GetJob(id), nil
+	}
+
+	// The import outlives the request but keeps its logger and trace; it is
+	// cancelled when RunJobs stops instead
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(stopped, cancel)
+	go func() {
+		defer running.Done()
+		defer stop()
+		defer cancel()
+		run(jobCtx, job, rows, false, actorID)
+	}()
+	return GetJob(id), nil
+}
+
+// run performs the import and records its outcome on job
+func run(ctx context.Context, job *Job, rows []Row, dryRun bool, actorID *int) {
+	// The report is only written by this goroutine, but read by GetJob,
+	// so it is updated on a copy and published under the lock
+	jobsMu.Lock()
+	job.Status = StatusRunning
+	report := job.Report.snapshot()
+	jobsMu.Unlock()
+
+	var err error
+	if dryRun {
+		err = Plan(ctx, rows, job.Mode, report)
+	} else {
+		err = Import(ctx, rows, job.Mode, report, actorID, func(done int) {
+			jobsMu.Lock()
+			job.Processed = done
+			job.Report = report.snapshot()
+			jobsMu.Unlock()
+		})
+	}
+
+	now := time.Now()
+	jobsMu.Lock()
+	job.Report = report
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = StatusSucceeded
+		if !dryRun {
+			job.Processed = job.Total
+		}
+	}
+	jobsMu.Unlock()
+
+	logger := logging.FromContext(ctx)
+	if err != nil {
+		logger.Error("User import failed", "job_id", job.ID, "err", err)
+	}
+	if dryRun {
+		return
+	}
+
+	entry := &models.AuditEntry{
+		ActorID: actorID,
+		Action:  models.AuditUserImport,
+		Metadata: map[string]interface{}{
+			"job_id":    job.ID,
+			"mode":      string(job.Mode),
+			"status":    job.Status,
+			"inserted":  report.Inserted,
+			"updated":   report.Updated,
+			"unchanged": report.Unchanged,
+			"failed":    report.Failed,
+		},
+	}
+	// A cancelled import is still recorded; RunJobs waits for it
+	if err := repository.RecordAudit(context.WithoutCancel(ctx), entry); err != nil {
+		logger.Error("Failed to record user import in audit log", "job_id", job.ID, "err", err)
+	}
+}
+
+// snapshot copies the report so it can be published while the import
+// carries on
+func (r *Report) snapshot() *Report {
+	c := *r
+	c.Errors = append([]RowError{}, r.Errors...)
+	return &c
+}
+
+// pruneJobs forgets jobs that finished more than jobRetention ago. The
+// caller must hold jobsMu.
+func pruneJobs() {
+	cutoff := time.Now().Add(-jobRetention)
+	for id, job := range jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(jobs, id)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,61 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"user-management-service/internal/models"
+)
+
+func largeImport() []Row {
+	rows := make([]Row, AsyncThreshold+1)
+	for i := range rows {
+		rows[i] = Row{Line: i + 2, User: models.User{Name: "User", Email: fmt.Sprintf("user-%d@example.com", i)}}
+	}
+	return rows
+}
+
+func TestStartRefusesBackgroundImportsWhenStopped(t *testing.T) {
+	rows := largeImport()
+	if _, err := Start(context.Background(), rows, ModeInsert, &Report{Total: len(rows)}, false, nil); !errors.Is(err, ErrJobsStopped) {
+		t.Errorf("got %v, want %v", err, ErrJobsStopped)
+	}
+}
+
+func TestRunJobsWaitsForBackgroundImports(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		RunJobs(ctx)
+		close(stopped)
+	}()
+
+	// Wait for RunJobs to accept imports
+	var job *Job
+	rows := largeImport()
+	for deadline := time.Now().Add(time.Second); ; {
+		var err error
+		job, err = Start(context.Background(), rows, ModeInsert, &Report{Total: len(rows)}, false, nil)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrJobsStopped) || time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("RunJobs did not stop")
+	}
+	// Without a database the import fails, but it must have finished
+	if got := GetJob(job.ID); got.FinishedAt == nil || got.Status != StatusFailed {
+		t.Errorf("got %+v, want a finished, failed job", got)
+	}
+}
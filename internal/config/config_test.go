@@ -0,0 +1,136 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every configuration variable for the duration of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	names := []string{"CONFIG_FILE"}
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		if name := typ.Field(i).Tag.Get("env"); name != "" {
+			names = append(names, name, name+"_FILE")
+		}
+	}
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.yaml", "port: \"9000\"\ndb_max_conns: 50\nlog_level: debug\n")
+	t.Setenv("PORT", "9100")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9100" {
+		t.Errorf("Port = %q, want the env value 9100", cfg.Port)
+	}
+	if cfg.DBMaxConns != 50 || cfg.LogLevel != "debug" {
+		t.Errorf("file values not applied: max conns %d, log level %q", cfg.DBMaxConns, cfg.LogLevel)
+	}
+	if cfg.DBReadTimeout != 5*time.Second {
+		t.Errorf("DBReadTimeout = %v, want the default", cfg.DBReadTimeout)
+	}
+	if cfg.OIDCIssuer != "http://localhost:9100" {
+		t.Errorf("OIDCIssuer = %q", cfg.OIDCIssuer)
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt", "from-a-mounted-secret\n"))
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.JWTSecret != "from-a-mounted-secret" {
+		t.Errorf("JWTSecret = %q", cfg.JWTSecret)
+	}
+
+	t.Setenv("JWT_SECRET", "inline")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "both JWT_SECRET and JWT_SECRET_FILE") {
+		t.Errorf("err = %v, want a conflict error", err)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.yaml", "db_max_con: 10\n")
+
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "db_max_con") {
+		t.Errorf("err = %v, want unknown field error", err)
+	}
+}
+
+func TestProductionValidation(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("APP_ENV", "production")
+
+	_, err := Load("")
+	if err == nil {
+		t.Fatal("expected production validation to fail with development defaults")
+	}
+	for _, want := range []string{"JWT_SECRET", "SMTP_EMAIL", "DATABASE_URL", "OIDC_SIGNING_KEY_FILE", "OIDC_ISSUER"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.JWTSecret = "super-secret-value"
+	cfg.SMTPPassword = "smtp-password"
+	cfg.DatabaseURL = "postgres://app:db-password@db:5432/users"
+
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, secret := range []string{"super-secret-value", "smtp-password", "db-password"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("output leaks %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "app:xxxxx@db:5432") {
+		t.Errorf("database URL should keep everything but the password:\n%s", out)
+	}
+}
+
+func TestMaskURLPassword(t *testing.T) {
+	for _, tc := range []struct{ raw, want string }{
+		{"host=db user=app password=db-password dbname=users", "host=db user=app password=******** dbname=users"},
+		{"host=db password = 'db pass\\'word' dbname=users", "host=db password = ******** dbname=users"},
+		{"postgres://db/users?sslmode=disable&password=db-password", "postgres://db/users?sslmode=disable&password=********"},
+		{"postgres://app:db%zzpassword@db/users", "********"},
+		{"host=db dbname=users", "host=db dbname=users"},
+	} {
+		if got := maskURLPassword(tc.raw); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
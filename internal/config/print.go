@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const masked = "********"
+
+// Print writes the effective configuration as YAML, in the format Load
+// accepts, with secrets masked
+func (c *Config) Print(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := formatValue(v.Field(i))
+		switch field.Tag.Get("secret") {
+		case "true":
+			if value != "" {
+				value = masked
+			}
+		case "url":
+			value = maskURLPassword(value)
+		}
+
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: field.Tag.Get("yaml"), LineComment: "env " + field.Tag.Get("env")},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value, Tag: scalarTag(v.Field(i))},
+		)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// scalarTag keeps strings that look like numbers or booleans quoted
+func scalarTag(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return "!!str"
+	}
+	return ""
+}
+
+// passwordParam matches the password of a keyword/value connection string
+// ("host=db password='a b'") or of a URL query parameter ("?password=x")
+var passwordParam = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s&]*)`)
+
+// maskURLPassword masks the password of a connection string, either in URL
+// form or as keyword/value pairs. URLs that do not parse are masked whole.
+func maskURLPassword(raw string) string {
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return masked
+		}
+		raw = u.Redacted()
+	}
+	return passwordParam.ReplaceAllString(raw, "${1}"+masked)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// minJWTSecretLength is the minimum production HMAC secret length, in bytes
+const minJWTSecretLength = 32
+
+// Validate reports every invalid setting at once. Production mode also
+// rejects settings that are only acceptable on a developer machine.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Environment != Development && c.Environment != Production {
+		fail("APP_ENV must be %q or %q, got %q", Development, Production, c.Environment)
+	}
+	if c.Port == "" {
+		fail("PORT is required")
+	}
+	if c.DatabaseURL == "" {
+		fail("DATABASE_URL is required")
+	}
+	if c.DBMaxConns < 1 {
+		fail("DB_MAX_CONNS must be at least 1")
+	}
+	if c.DBMinConns < 0 || c.DBMinConns > c.DBMaxConns {
+		fail("DB_MIN_CONNS must be between 0 and DB_MAX_CONNS (%d)", c.DBMaxConns)
+	}
+
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"DB_CONNECT_TIMEOUT", c.DBConnectTimeout},
+		{"DB_READ_TIMEOUT", c.DBReadTimeout},
+		{"DB_WRITE_TIMEOUT", c.DBWriteTimeout},
+		{"DB_BULK_TIMEOUT", c.DBBulkTimeout},
+		{"HTTP_READ_HEADER_TIMEOUT", c.ReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", c.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+	} {
+		if timeout.value <= 0 {
+			fail("%s must be positive", timeout.name)
+		}
+	}
+	if c.ReadinessDrainDelay < 0 || c.ReadinessDrainDelay >= c.ShutdownTimeout {
+		fail("READINESS_DRAIN_DELAY must be shorter than SHUTDOWN_TIMEOUT")
+	}
+
+	if c.GraphQLMaxComplexity < 0 || c.GraphQLMaxDepth < 0 {
+		fail("GRAPHQL_MAX_COMPLEXITY and GRAPHQL_MAX_DEPTH must not be negative")
+	}
+
+	if c.AccountDeletionGracePeriod < 0 {
+		fail("ACCOUNT_DELETION_GRACE_PERIOD must not be negative")
+	}
+	if c.LoginAttemptRetention < 0 {
+		fail("LOGIN_ATTEMPT_RETENTION must not be negative")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		fail("LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		fail("OTEL_TRACES_SAMPLE_RATIO must be between 0 and 1")
+	}
+
+	if c.IsProduction() {
+		if c.JWTSecret == defaultJWTSecret || len(c.JWTSecret) < minJWTSecretLength {
+			fail("JWT_SECRET must be set to a random value of at least %d bytes in production", minJWTSecretLength)
+		}
+		if c.SMTPEmail == "" || c.SMTPPassword == "" {
+			fail("SMTP_EMAIL and SMTP_PASSWORD are required in production; without them OTPs are written to a local file")
+		}
+		if c.DatabaseURL == defaultDatabaseURL {
+			fail("DATABASE_URL must be set explicitly in production")
+		}
+		if c.OIDCSigningKeyFile == "" {
+			fail("OIDC_SIGNING_KEY_FILE is required in production; an ephemeral key invalidates ID tokens on restart")
+		}
+		if strings.HasPrefix(c.OIDCIssuer, "http://localhost") {
+			fail("OIDC_ISSUER must be set to the public URL in production")
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
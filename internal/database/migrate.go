@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"user-management-service/migrations"
+)
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version TEXT PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Migrate applies every embedded migration that has not been recorded in
+// schema_migrations yet, each in its own transaction, and returns the
+// versions it applied.
+func Migrate(ctx context.Context) ([]string, error) {
+	if DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	if _, err := DB.Exec(ctx, createMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, version := range migrations.Versions() {
+		var exists bool
+		if err := DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&exists); err != nil {
+			return applied, err
+		}
+		if exists {
+			continue
+		}
+
+		sql, err := migrations.FS.ReadFile(version + ".sql")
+		if err != nil {
+			return applied, err
+		}
+
+		tx, err := DB.Begin(ctx)
+		if err != nil {
+			return applied, err
+		}
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("migration %s: %v", version, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			tx.Rollback(ctx)
+			return applied, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return applied, err
+		}
+		applied = append(applied, version)
+	}
+	return applied, nil
+}
+
+// SchemaVersion returns the most recently applied migration version
+func SchemaVersion(ctx context.Context) (string, error) {
+	if DB == nil {
+		return "", errors.New("database connection is not initialized")
+	}
+
+	var version *string
+	err := DB.QueryRow(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return "", err
+	}
+	if version == nil {
+		return "", nil
+	}
+	return *version, nil
+}
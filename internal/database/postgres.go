@@ -3,46 +3,138 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"user-management-service/internal/config"
+	"user-management-service/internal/tracing"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var DB *pgxpool.Pool
 
-func ConnectDB(databaseURL string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// PoolSettings size and recycle the connection pool
+type PoolSettings struct {
+	MaxConns        int
+	MinConns        int
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
+// DefaultPoolSettings suit a single service instance
+var DefaultPoolSettings = PoolSettings{
+	MaxConns:        25,
+	MinConns:        5,
+	MaxConnLifetime: time.Hour,
+	MaxConnIdleTime: 10 * time.Minute,
+}
+
+// PoolSettingsFrom reads the pool settings from the service configuration
+func PoolSettingsFrom(cfg *config.Config) PoolSettings {
+	return PoolSettings{
+		MaxConns:        cfg.DBMaxConns,
+		MinConns:        cfg.DBMinConns,
+		MaxConnLifetime: cfg.DBMaxConnLifetime,
+		MaxConnIdleTime: cfg.DBMaxConnIdleTime,
+	}
+}
+
+// Connection retry backoff, doubling from the first delay up to the maximum
+const (
+	retryInitialDelay = 250 * time.Millisecond
+	retryMaxDelay     = 5 * time.Second
+)
+
+// replicaPingTimeout bounds the startup check of the read replica
+const replicaPingTimeout = 5 * time.Second
+
+// ConnectDB opens the primary pool, retrying with backoff until the database
+// answers a ping or ctx expires. This lets the service start alongside a
+// database that is still booting.
+func ConnectDB(ctx context.Context, databaseURL string, pool PoolSettings) error {
+	p, err := newPool(databaseURL, pool)
+	if err != nil {
+		return err
+	}
+
+	delay := retryInitialDelay
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		err = p.Ping(ctx)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			p.Close()
+			// Report why connecting failed rather than the expired deadline
+			if lastErr == nil {
+				lastErr = err
+			}
+			return fmt.Errorf("unable to connect to database after %d attempts (check DATABASE_URL and that PostgreSQL is running): %v", attempt, lastErr)
+		}
+		lastErr = err
+
+		slog.Warn("Database not reachable, retrying", "attempt", attempt, "retry_in", delay.String(), "err", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+
+	DB = p
+	slog.Info("Connected to PostgreSQL successfully")
+	return nil
+}
+
+// Connect opens the primary pool and, when configured, the read replica,
+// giving up after cfg.DBConnectTimeout
+func Connect(cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DBConnectTimeout)
+	defer cancel()
+
+	pool := PoolSettingsFrom(cfg)
+	if err := ConnectDB(ctx, cfg.DatabaseURL, pool); err != nil {
+		return err
+	}
+	if cfg.DatabaseReplicaURL == "" {
+		return nil
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), replicaPingTimeout)
 	defer cancel()
+	return ConnectReplica(ctx, cfg.DatabaseReplicaURL, pool)
+}
 
+func newPool(databaseURL string, pool PoolSettings) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
-		log.Fatalf("Unable to parse database URL: %v", err)
+		return nil, fmt.Errorf("unable to parse database URL: %v", err)
 	}
 
 	// Optimization: Increase max connections for higher throughput
-	config.MaxConns = 25
-	config.MinConns = 5
-	config.MaxConnLifetime = time.Hour
-	config.MaxConnIdleTime = 10 * time.Minute
+	config.MaxConns = int32(pool.MaxConns)
+	config.MinConns = int32(pool.MinConns)
+	config.MaxConnLifetime = pool.MaxConnLifetime
+	config.MaxConnIdleTime = pool.MaxConnIdleTime
+	config.ConnConfig.Tracer = tracing.QueryTracer{}
 
-	// Create the connection pool
-	DB, err = pgxpool.NewWithConfig(context.Background(), config)
+	// Creating the pool does not connect; connections are opened on demand
+	p, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
-		log.Fatalf("Unable to create connection pool: %v", err)
+		return nil, fmt.Errorf("unable to create connection pool: %v", err)
 	}
-
-	// Verify connection with a ping using the timeout context
-	if err := DB.Ping(ctx); err != nil {
-		log.Fatalf("Unable to connect to database (ping failed): %v\nCheck your DATABASE_URL and ensure PostgreSQL is running.", err)
-	}
-
-	fmt.Println("Connected to PostgreSQL successfully")
+	return p, nil
 }
 
 func CloseDB() {
+	if Replica != nil {
+		Replica.Close()
+		Replica = nil
+	}
 	if DB != nil {
 		DB.Close()
-		fmt.Println("Database connection closed")
+		slog.Info("Database connection closed")
 	}
 }
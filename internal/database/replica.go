@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"user-management-service/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Replica is an optional read-only pool. When nil, reads go to DB.
+var Replica *pgxpool.Pool
+
+// ConnectReplica opens the read-replica pool. An unreachable replica is not
+// fatal: reads fall back to the primary until it comes back.
+func ConnectReplica(ctx context.Context, databaseURL string, pool PoolSettings) error {
+	p, err := newPool(databaseURL, pool)
+	if err != nil {
+		return err
+	}
+	if err := p.Ping(ctx); err != nil {
+		slog.Warn("Read replica not reachable, reads will use the primary until it is", "err", err)
+	} else {
+		slog.Info("Connected to PostgreSQL read replica successfully")
+	}
+	Replica = p
+	return nil
+}
+
+type primaryKey struct{}
+
+// WithPrimary pins reads made with the returned context to the primary, for
+// callers that must see their own or very recent writes
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Read runs a read-only query against the replica when one is configured,
+// repeating it on the primary if the replica fails. A missing row is also
+// confirmed on the primary, since the replica may not have caught up yet.
+func Read(ctx context.Context, query func(*pgxpool.Pool) error) error {
+	if Replica == nil || ctx.Value(primaryKey{}) != nil {
+		return query(DB)
+	}
+
+	err := query(Replica)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		logging.FromContext(ctx).Warn("Read replica query failed, retrying on primary", "err", err)
+	}
+	return query(DB)
+}
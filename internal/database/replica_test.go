@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// fakePools installs unconnected primary and replica pools; pgxpool only
+// dials when a connection is acquired, so the tests never touch a server
+func fakePools(t *testing.T) (primary, replica *pgxpool.Pool) {
+	t.Helper()
+	var err error
+	if primary, err = pgxpool.New(context.Background(), "postgres://primary.invalid/db"); err != nil {
+		t.Fatal(err)
+	}
+	if replica, err = pgxpool.New(context.Background(), "postgres://replica.invalid/db"); err != nil {
+		t.Fatal(err)
+	}
+	DB, Replica = primary, replica
+	t.Cleanup(func() {
+		primary.Close()
+		replica.Close()
+		DB, Replica = nil, nil
+	})
+	return primary, replica
+}
+
+func TestReadPrefersReplica(t *testing.T) {
+	_, replica := fakePools(t)
+
+	var used []*pgxpool.Pool
+	err := Read(context.Background(), func(db *pgxpool.Pool) error {
+		used = append(used, db)
+		return nil
+	})
+	if err != nil || len(used) != 1 || used[0] != replica {
+		t.Errorf("err = %v, queried %d pools, want the replica once", err, len(used))
+	}
+}
+
+func TestReadFallsBackToPrimary(t *testing.T) {
+	for _, replicaErr := range []error{errors.New("connection refused"), pgx.ErrNoRows} {
+		primary, replica := fakePools(t)
+
+		var used []*pgxpool.Pool
+		err := Read(context.Background(), func(db *pgxpool.Pool) error {
+			used = append(used, db)
+			if db == replica {
+				return replicaErr
+			}
+			return nil
+		})
+		if err != nil || len(used) != 2 || used[1] != primary {
+			t.Errorf("replica error %v: err = %v, want a successful retry on the primary", replicaErr, err)
+		}
+	}
+}
+
+func TestWithPrimarySkipsReplica(t *testing.T) {
+	primary, _ := fakePools(t)
+
+	var used []*pgxpool.Pool
+	Read(WithPrimary(context.Background()), func(db *pgxpool.Pool) error {
+		used = append(used, db)
+		return nil
+	})
+	if len(used) != 1 || used[0] != primary {
+		t.Errorf("queried %v, want only the primary", used)
+	}
+}
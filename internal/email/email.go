@@ -1,11 +1,19 @@
 package email
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/smtp"
 	"os"
+	"time"
 	"user-management-service/internal/config"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/metrics"
+	"user-management-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var smtpCfg *config.Config
@@ -17,17 +25,55 @@ func Init(cfg *config.Config) {
 
 // SendOTPEmail sends a 6-digit OTP code to the specified email address.
 // It uses the configuration provided during Init().
-func SendOTPEmail(to string, otp string) error {
+func SendOTPEmail(ctx context.Context, to string, otp string) error {
+	body := fmt.Sprintf("Your 6-digit verification code is: %s\nThis code expires in 10 minutes.", otp)
+	return send(ctx, to, "Your OTP Code", body, otp)
+}
+
+// SendEmailChangeConfirmation sends the token that confirms an email change
+// to the new address
+func SendEmailChangeConfirmation(ctx context.Context, to string, token string, ttl time.Duration) error {
+	body := fmt.Sprintf("Someone asked to change the email of their account to this address.\n"+
+		"To confirm, enter this code: %s\nIt expires in %d minutes. If this wasn't you, ignore this email.", token, int(ttl.Minutes()))
+	return send(ctx, to, "Confirm your new email address", body, token)
+}
+
+// SendEmailChangeNotice tells the current address that a change of email to
+// newEmail was requested, so a hijacked account does not go unnoticed
+func SendEmailChangeNotice(ctx context.Context, to string, newEmail string) error {
+	body := fmt.Sprintf("A change of your account's email to %s was requested. It takes effect once confirmed from that address.\n"+
+		"If this wasn't you, contact your administrator.", newEmail)
+	return send(ctx, to, "Your email address is being changed", body, "")
+}
+
+// SendAccountDeletionNotice confirms a request to delete an account and
+// says when it takes effect
+func SendAccountDeletionNotice(ctx context.Context, to string, at time.Time) error {
+	body := fmt.Sprintf("Your account is scheduled to be deleted on %s. Until then, you can cancel this by signing in.\n"+
+		"If this wasn't you, sign in and cancel the deletion, then contact your administrator.", at.UTC().Format("2 January 2006 15:04 MST"))
+	return send(ctx, to, "Your account will be deleted", body, "")
+}
+
+// send delivers a message. Without SMTP credentials nothing is sent; the
+// code, if any, is written to otp_debug.log instead.
+func send(ctx context.Context, to, subject, body, code string) error {
+	_, span := tracing.Tracer().Start(ctx, "smtp.send", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
 	if smtpCfg == nil {
 		return fmt.Errorf("email package not initialized")
 	}
 
-	// For development: If SMTPEmail is not set, log the OTP to a file
+	// For development: If SMTPEmail is not set, log the code to a file
 	if smtpCfg.SMTPEmail == "" {
-		log.Printf("DEMO MODE: Sending OTP %s to %s\n", otp, to)
-		if err := os.WriteFile("otp_debug.log", []byte(otp), 0644); err != nil {
-			log.Printf("Failed to write otp_debug.log: %v", err)
+		logging.FromContext(ctx).Info("DEMO MODE: email not sent; any code is written to otp_debug.log", "email", to, "subject", subject)
+		if code != "" {
+			if err := os.WriteFile("otp_debug.log", []byte(code), 0644); err != nil {
+				logging.FromContext(ctx).Error("Failed to write otp_debug.log", "err", err)
+			}
 		}
+		metrics.EmailSent("demo")
+		span.SetAttributes(attribute.String("email.outcome", "demo"))
 		return nil
 	}
 
@@ -37,8 +83,6 @@ func SendOTPEmail(to string, otp string) error {
 	smtpPort := smtpCfg.SMTPPort
 
 	// Message composition
-	subject := "Your OTP Code"
-	body := fmt.Sprintf("Your 6-digit verification code is: %s\nThis code expires in 10 minutes.", otp)
 	message := []byte(fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", to, subject, body))
 
 	// Authentication
@@ -48,9 +92,14 @@ func SendOTPEmail(to string, otp string) error {
 	addr := fmt.Sprintf("%s:%s", smtpHost, smtpPort)
 	err := smtp.SendMail(addr, auth, from, []string{to}, message)
 	if err != nil {
+		metrics.EmailSent("failed")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("failed to send email: %v", err)
 	}
+	metrics.EmailSent("sent")
+	span.SetAttributes(attribute.String("email.outcome", "sent"))
 
-	log.Printf("OTP email successfully sent to %s", to)
+	logging.FromContext(ctx).Info("Email sent", "email", to, "subject", subject)
 	return nil
 }
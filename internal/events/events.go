@@ -0,0 +1,77 @@
+// Package events delivers change notifications to subscribers in this
+// process. Changes are published by database triggers with NOTIFY, so every
+// replica's Listen relays every change, whichever process made it.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"user-management-service/internal/models"
+)
+
+// Event types
+const (
+	UserChanged    = "user.changed"
+	UserDeleted    = "user.deleted"
+	SessionRevoked = "session.revoked"
+	// GroupsChanged is published when group roles, members or nesting
+	// change, which may change the effective roles of any user
+	GroupsChanged = "groups.changed"
+)
+
+// Channel is the NOTIFY channel the triggers publish on
+const Channel = "ums_events"
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// events are dropped for it
+const subscriberBuffer = 64
+
+// Event is a change notification. User is set for UserChanged, SessionID
+// for SessionRevoked.
+type Event struct {
+	Type      string       `json:"type"`
+	UserID    int          `json:"user_id"`
+	User      *models.User `json:"user,omitempty"`
+	SessionID string       `json:"session_id,omitempty"`
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = make(map[chan Event]struct{})
+)
+
+// Subscribe returns a channel receiving every event until ctx is done, when
+// it is closed
+func Subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, subscriberBuffer)
+
+	mu.Lock()
+	subscribers[ch] = struct{}{}
+	mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		delete(subscribers, ch)
+		mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// Publish delivers e to this process's subscribers. A subscriber that is
+// not keeping up misses the event rather than holding up the others.
+func Publish(e Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			slog.Warn("Dropping event for slow subscriber", "type", e.Type, "user_id", e.UserID)
+		}
+	}
+}
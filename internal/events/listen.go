@@ -0,0 +1,75 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"user-management-service/internal/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Reconnect backoff for the listener connection
+const (
+	listenInitialDelay = time.Second
+	listenMaxDelay     = 30 * time.Second
+)
+
+// Listen relays notifications on Channel to Publish until ctx is cancelled.
+// It holds its own connection outside the pool and reconnects with backoff;
+// changes made while it is disconnected are not delivered.
+func Listen(ctx context.Context) {
+	delay := listenInitialDelay
+	for {
+		connected, err := listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = listenInitialDelay
+		}
+
+		slog.Warn("Event listener disconnected, reconnecting", "retry_in", delay.String(), "err", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(delay*2, listenMaxDelay)
+	}
+}
+
+// listen runs one LISTEN session and reports whether it got that far
+func listen(ctx context.Context) (bool, error) {
+	if database.DB == nil {
+		return false, errors.New("database connection is not initialized")
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, database.DB.Config().ConnConfig.Copy())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return false, err
+	}
+	slog.Debug("Listening for change events", "channel", Channel)
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		var e Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			slog.Warn("Ignoring malformed event", "err", err)
+			continue
+		}
+		Publish(e)
+	}
+}
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"time"
 
 	"user-management-service/internal/auth"
 	"user-management-service/internal/email"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/metrics"
 	"user-management-service/internal/models"
 	"user-management-service/internal/repository"
 )
@@ -34,7 +36,7 @@ func RequestOTP(w http.ResponseWriter, r *http.Request) {
 	// 1. Generate OTP
 	otpCode, err := auth.GenerateOTP()
 	if err != nil {
-		log.Printf("Failed to generate OTP: %v", err)
+		logging.FromContext(r.Context()).Error("Failed to generate OTP", "err", err)
 		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
 		return
 	}
@@ -46,15 +48,16 @@ func RequestOTP(w http.ResponseWriter, r *http.Request) {
 		ExpiresAt: time.Now().Add(10 * time.Minute),
 	}
 
-	if err := repository.SaveOTP(otp); err != nil {
-		log.Printf("Failed to save OTP: %v", err)
+	if err := repository.SaveOTP(r.Context(), otp); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to save OTP", "err", err)
 		http.Error(w, `{"error": "Failed to process request"}`, http.StatusInternalServerError)
 		return
 	}
+	metrics.OTPIssued()
 
 	// 3. Send OTP Email
-	if err := email.SendOTPEmail(payload.Email, otpCode); err != nil {
-		log.Printf("Failed to send OTP email to %s: %v", payload.Email, err)
+	if err := email.SendOTPEmail(r.Context(), payload.Email, otpCode); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to send OTP email", "email", payload.Email, "err", err)
 		// Note: OTP is saved but email failed. Client can retry.
 		http.Error(w, `{"error": "Failed to send OTP email"}`, http.StatusInternalServerError)
 		return
@@ -84,52 +87,28 @@ func VerifyOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. Get Latest OTP
-	storedOTP, err := repository.GetLatestOTP(payload.Email)
-	if err != nil {
-		log.Printf("Database error fetching OTP: %v", err)
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
-		return
-	}
-
-	if storedOTP == nil {
-		http.Error(w, `{"error": "Invalid or expired OTP"}`, http.StatusUnauthorized)
-		return
-	}
-
-	// 2. Validate OTP
-	if storedOTP.IsUsed {
-		http.Error(w, `{"error": "OTP already used"}`, http.StatusUnauthorized)
+	// 1. Validate the OTP and mark it as used
+	if err := auth.ConsumeOTP(r.Context(), payload.Email, payload.OTP); err != nil {
+		logging.FromContext(r.Context()).Warn("OTP verification failed", "email", payload.Email, "err", err)
+		writeJSONError(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	if storedOTP.ExpiresAt.Before(time.Now()) {
-		http.Error(w, `{"error": "OTP expired"}`, http.StatusUnauthorized)
+	// 2. Find or create the user and start a session
+	user, err := auth.SignInWithEmail(r.Context(), payload.Email, models.RoleUser)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to sign in user", "err", err)
+		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
 		return
 	}
 
-	if storedOTP.OTP != payload.OTP {
-		// Increment attempts
-		repository.IncrementOTPAttempts(storedOTP.ID)
-		http.Error(w, `{"error": "Invalid OTP"}`, http.StatusUnauthorized)
+	token, err := auth.IssueSession(r.Context(), user)
+	if errors.Is(err, repository.ErrUserDeactivated) {
+		http.Error(w, `{"error": "Account is deactivated"}`, http.StatusForbidden)
 		return
 	}
-
-	// 3. Mark OTP as used
-	if err := repository.MarkOTPAsUsed(storedOTP.ID); err != nil {
-		log.Printf("Failed to mark OTP as used: %v", err)
-	}
-
-	// 4. Generate JWT Token
-	role := models.RoleUser
-	user, err := repository.GetUserByEmail(payload.Email)
-	if err == nil && user != nil {
-		role = user.Role
-	}
-
-	token, err := auth.GenerateJWT(payload.Email, payload.Email, role)
 	if err != nil {
-		log.Printf("Failed to generate JWT: %v", err)
+		logging.FromContext(r.Context()).Error("Failed to generate JWT", "err", err)
 		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-management-service/internal/bulk"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// exportTimeout bounds a single export, which may take longer than the
+// repository's bulk timeout on a large table
+const exportTimeout = 30 * time.Minute
+
+// exportWriteDeadline is how long the client may take to read each chunk
+// of an export
+const exportWriteDeadline = time.Minute
+
+// requireAdmin writes an error and returns nil unless the request was made
+// by an admin whose credential carries scope. Writes are refused while
+// impersonating, as they are over GraphQL.
+func requireAdmin(w http.ResponseWriter, r *http.Request, scope string) *middleware.User {
+	userinfo := middleware.ForContext(r.Context())
+	if userinfo == nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return nil
+	}
+	if !userinfo.HasRole(models.RoleAdmin) || !userinfo.HasScope(scope) {
+		http.Error(w, `{"error": "Access denied"}`, http.StatusForbidden)
+		return nil
+	}
+	if scope == models.ScopeWrite && userinfo.IsImpersonated() {
+		http.Error(w, `{"error": "Not allowed while impersonating"}`, http.StatusForbidden)
+		return nil
+	}
+	return userinfo
+}
+
+// ImportUsers handles a CSV or NDJSON user import, sent either as the
+// request body or as the "file" field of a multipart form. Query
+// parameters: mode (insert or upsert), dry_run and format, which is
+// otherwise detected from the file name or content type. Large imports
+// are answered with 202 and a Location to poll.
+func ImportUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	userinfo := requireAdmin(w, r, models.ScopeWrite)
+	if userinfo == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	mode, err := bulk.ParseMode(query.Get("mode"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(query.Get("dry_run"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, bulk.MaxUploadSize)
+	var body io.Reader = r.Body
+	filename, contentType := "", r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeJSONError(w, "Expected the file in a multipart field named file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body, filename, contentType = file, header.Filename, header.Header.Get("Content-Type")
+	}
+
+	format := bulk.DetectFormat(filename, contentType)
+	if name := query.Get("format"); name != "" {
+		if format, err = bulk.ParseFormat(name); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	rows, report, err := bulk.Parse(body, format)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var actorID *int
+	if id, err := strconv.Atoi(userinfo.ID); err == nil {
+		actorID = &id
+	}
+	job, err := bulk.Start(r.Context(), rows, mode, report, dryRun, actorID)
+	if errors.Is(err, bulk.ErrJobsStopped) {
+		writeJSONError(w, "Large imports are not accepted while the service shuts down", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to start user import", "err", err)
+		http.Error(w, `{"error": "Failed to import users"}`, http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if job.FinishedAt == nil {
+		w.Header().Set("Location", "/users/import/"+job.ID)
+		status = http.StatusAccepted
+	}
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		logging.FromContext(r.Context()).Error("ImportUsers encode error", "err", err)
+	}
+}
+
+// GetImportJob reports the progress of an import
+func GetImportJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if requireAdmin(w, r, models.ScopeRead) == nil {
+		return
+	}
+
+	job := bulk.GetJob(mux.Vars(r)["id"])
+	if job == nil {
+		http.Error(w, `{"error": "Import job not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		logging.FromContext(r.Context()).Error("GetImportJob encode error", "err", err)
+	}
+}
+
+// ExportUsers streams users as CSV or NDJSON. Query parameters: format
+// (csv or ndjson), fields (comma separated, default all), and the filters
+// role, email_domain and q, a substring of the name or email.
+func ExportUsers(w http.ResponseWriter, r *http.Request) {
+	if requireAdmin(w, r, models.ScopeRead) == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := bulk.ParseFormat(query.Get("format"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := bulk.ParseFields(query.Get("fields"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := repository.UserFilter{
+		Role:        strings.ToUpper(query.Get("role")),
+		EmailDomain: query.Get("email_domain"),
+		Search:      query.Get("q"),
+	}
+
+	if format == bulk.FormatNDJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="users.`+string(format)+`"`)
+
+	// The server's write timeout is meant for ordinary responses; push the
+	// deadline out as each chunk is sent instead
+	rc := http.NewResponseController(w)
+	extend := func() error {
+		if err := rc.SetWriteDeadline(time.Now().Add(exportWriteDeadline)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+	if err := rc.SetWriteDeadline(time.Now().Add(exportWriteDeadline)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logging.FromContext(r.Context()).Warn("ExportUsers could not extend write deadline", "err", err)
+	}
+
+	ctx := repository.WithTimeout(r.Context(), exportTimeout)
+	if err := bulk.Export(ctx, w, format, fields, filter, extend); err != nil {
+		// Rows may already have been sent, so the status cannot change; cut
+		// the response short so the client sees a truncated body, not a
+		// complete-looking file
+		logging.FromContext(r.Context()).Error("Failed to export users", "err", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// writeJSONError writes message as a JSON error body
+func writeJSONError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
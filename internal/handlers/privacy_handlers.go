@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"user-management-service/internal/logging"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/privacy"
+	"user-management-service/internal/repository"
+)
+
+// ExportMyData sends the signed-in user a ZIP archive of everything stored
+// about them. It is refused while impersonating, as over GraphQL.
+func ExportMyData(w http.ResponseWriter, r *http.Request) {
+	userinfo := middleware.ForContext(r.Context())
+	if userinfo == nil {
+		writeJSONError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	if !userinfo.HasScope(models.ScopeRead) || userinfo.IsImpersonated() {
+		writeJSONError(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	id, err := strconv.Atoi(userinfo.ID)
+	if err != nil {
+		writeJSONError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	user, err := repository.GetUserByID(r.Context(), id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ExportMyData internal error", "id", id, "err", err)
+		writeJSONError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		writeJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// Build the archive first, so a failure can still be reported
+	var buf bytes.Buffer
+	if err := privacy.WriteExport(r.Context(), &buf, user); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to export user data", "user_id", id, "err", err)
+		writeJSONError(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+
+	err = repository.RecordAudit(r.Context(), &models.AuditEntry{
+		ActorID:   &id,
+		SubjectID: &id,
+		Action:    models.AuditDataExported,
+		Metadata:  map[string]interface{}{"source": "rest"},
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record data export", "user_id", id, "err", err)
+	}
+
+	w.Header().Set("Content-Type", privacy.ExportContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+privacy.ExportFilename(user)+`"`)
+	w.Write(buf.Bytes())
+}
@@ -2,10 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"user-management-service/internal/auth"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/middleware"
 	"user-management-service/internal/models"
 	"user-management-service/internal/repository"
 
@@ -18,21 +25,26 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(map[string]string{"status": "healthy"}); err != nil {
-		log.Printf("HealthCheck encode error: %v", err)
+		logging.FromContext(r.Context()).Error("HealthCheck encode error", "err", err)
 	}
 }
 
-// CreateUser handles user creation
+// CreateUser handles user creation. Admins only; new users get the USER
+// role, which is changed through PATCH /users/{id}/role.
 func CreateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	defer r.Body.Close()
 
+	if requireAdmin(w, r, models.ScopeWrite) == nil {
+		return
+	}
+
 	var user models.User
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 
 	if err := decoder.Decode(&user); err != nil {
-		log.Printf("CreateUser decode error: %v", err)
+		logging.FromContext(r.Context()).Warn("CreateUser decode error", "err", err)
 		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
 		return
 	}
@@ -41,16 +53,21 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "Name and Email are required"}`, http.StatusBadRequest)
 		return
 	}
+	if user.Role != "" {
+		writeJSONError(w, "role cannot be set on creation; use PATCH /users/{id}/role", http.StatusBadRequest)
+		return
+	}
+	user.Role = models.RoleUser
 
-	if err := repository.CreateUser(&user); err != nil {
-		log.Printf("Failed to create user: %v", err)
+	if err := repository.CreateUser(r.Context(), &user); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to create user", "err", err)
 		http.Error(w, `{"error": "Failed to create user"}`, http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(user); err != nil {
-		log.Printf("CreateUser encode error: %v", err)
+		logging.FromContext(r.Context()).Error("CreateUser encode error", "err", err)
 	}
 }
 
@@ -71,9 +88,9 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := repository.GetUserByID(id)
+	user, err := repository.GetUserByID(r.Context(), id)
 	if err != nil {
-		log.Printf("GetUser internal error for ID %d: %v", id, err)
+		logging.FromContext(r.Context()).Error("GetUser internal error", "id", id, "err", err)
 		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
 		return
 	}
@@ -83,12 +100,53 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", etag(user.Version))
 	if err := json.NewEncoder(w).Encode(user); err != nil {
-		log.Printf("GetUser encode error: %v", err)
+		logging.FromContext(r.Context()).Error("GetUser encode error", "err", err)
+	}
+}
+
+// SearchUsers handles GET /users/search?q=...&limit=..., answering with the
+// matching users best first, each with its score and highlighted matches.
+// Admins only.
+func SearchUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if requireAdmin(w, r, models.ScopeRead) == nil {
+		return
+	}
+
+	limit := repository.DefaultSearchLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > repository.MaxSearchLimit {
+			writeJSONError(w, fmt.Sprintf("limit must be between 1 and %d", repository.MaxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	results, err := repository.SearchUsers(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrEmptySearch) {
+			writeJSONError(w, "q is required", http.StatusBadRequest)
+			return
+		}
+		writeJSONError(w, "Failed to search users", http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []*models.UserSearchResult{}
+	}
+
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		logging.FromContext(r.Context()).Error("SearchUsers encode error", "err", err)
 	}
 }
 
-// UpdateUser handles updating a user
+// UpdateUser handles replacing a user's name and email. With an If-Match
+// header the update only applies if the user is still at that version. A
+// new email only takes effect once confirmed, see saveUser.
 func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	defer r.Body.Close()
@@ -106,27 +164,290 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	caller := requireSelfOrAdmin(w, r, id)
+	if caller == nil {
+		return
+	}
+
+	expectedVersion, ok := ifMatchVersion(r)
+	if !ok {
+		writeJSONError(w, "The user was changed by someone else; reload it and try again", http.StatusPreconditionFailed)
+		return
+	}
+
 	var user models.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
 		return
 	}
-	user.ID = id
 
-	if err := repository.UpdateUser(&user); err != nil {
-		log.Printf("Failed to update user: %v", err)
-		http.Error(w, `{"error": "Failed to update user"}`, http.StatusInternalServerError)
+	// PUT replaces the user, so leaving a field out would blank it
+	if strings.TrimSpace(user.Name) == "" || strings.TrimSpace(user.Email) == "" {
+		http.Error(w, `{"error": "Name and Email are required"}`, http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	saveUser(w, r, caller, id, repository.UserPatch{Name: &user.Name, Email: &user.Email}, expectedVersion)
+}
+
+// PatchUser handles a partial update as a JSON Merge Patch (RFC 7396):
+// only the fields present in the body change. Like UpdateUser it honours
+// If-Match.
+func PatchUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	caller := requireSelfOrAdmin(w, r, id)
+	if caller == nil {
+		return
+	}
+
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/merge-patch+json" && ct != "application/json" {
+		writeJSONError(w, "Content-Type must be application/merge-patch+json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	expectedVersion, ok := ifMatchVersion(r)
+	if !ok {
+		writeJSONError(w, "The user was changed by someone else; reload it and try again", http.StatusPreconditionFailed)
+		return
+	}
+
+	patch, err := parseMergePatch(r.Body)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saveUser(w, r, caller, id, patch, expectedVersion)
+}
+
+// requireSelfOrAdmin writes an error and returns nil unless the request was
+// made by user id or by an admin, with a credential carrying the write
+// scope and not while impersonating
+func requireSelfOrAdmin(w http.ResponseWriter, r *http.Request, id int) *middleware.User {
+	userinfo := middleware.ForContext(r.Context())
+	if userinfo == nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return nil
+	}
+	if userinfo.ID != strconv.Itoa(id) && !userinfo.HasRole(models.RoleAdmin) || !userinfo.HasScope(models.ScopeWrite) {
+		http.Error(w, `{"error": "Access denied"}`, http.StatusForbidden)
+		return nil
+	}
+	if userinfo.IsImpersonated() {
+		http.Error(w, `{"error": "Not allowed while impersonating"}`, http.StatusForbidden)
+		return nil
+	}
+	return userinfo
+}
+
+// saveUser applies a patch and answers with the updated user. A new email is
+// not applied: it is mailed a token to confirm the change with, as for
+// GraphQL's requestEmailChange, so a mistyped address cannot lock the user out.
+// caller must have passed requireSelfOrAdmin.
+func saveUser(w http.ResponseWriter, r *http.Request, caller *middleware.User, id int, patch repository.UserPatch, expectedVersion *int) {
+	newEmail := patch.Email
+	patch.Email = nil
+
+	user, err := repository.PatchUser(r.Context(), id, patch, expectedVersion)
+	if err == nil && newEmail != nil && strings.TrimSpace(*newEmail) != user.Email {
+		previous := user.Email
+		user, err = auth.RequestEmailChange(r.Context(), user, *newEmail)
+		if err == nil {
+			auditEmailChangeRequest(r, caller, id, previous, strings.TrimSpace(*newEmail))
+		}
+	}
+	writeUpdatedUser(w, r, user, err)
+}
+
+// auditEmailChangeRequest records an email change caller requested through
+// the API
+func auditEmailChangeRequest(r *http.Request, caller *middleware.User, id int, from, to string) {
+	actorID, err := strconv.Atoi(caller.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record email change request", "user_id", id, "err", err)
+		return
+	}
+	err = repository.RecordAudit(r.Context(), &models.AuditEntry{
+		ActorID:   &actorID,
+		SubjectID: &id,
+		Action:    models.AuditEmailChangeRequested,
+		Metadata:  map[string]interface{}{"from": from, "to": to, "source": "rest"},
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record email change request", "user_id", id, "err", err)
+	}
+}
+
+// parseMergePatch reads a merge patch of a user. Only name and email can be
+// patched; they cannot be removed, so null is rejected for them.
+func parseMergePatch(body io.Reader) (repository.UserPatch, error) {
+	var patch repository.UserPatch
+
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&fields); err != nil || fields == nil {
+		return patch, errors.New("request body must be a JSON object")
+	}
+
+	for name, raw := range fields {
+		var target **string
+		switch name {
+		case "name":
+			target = &patch.Name
+		case "email":
+			target = &patch.Email
+		case "role":
+			return patch, errors.New("change the role with PATCH /users/{id}/role")
+		default:
+			return patch, fmt.Errorf("field %q cannot be changed", name)
+		}
+
+		var value *string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return patch, fmt.Errorf("field %q must be a string", name)
+		}
+		if value == nil || strings.TrimSpace(*value) == "" {
+			return patch, fmt.Errorf("field %q cannot be empty", name)
+		}
+		*target = value
+	}
+	return patch, nil
+}
+
+// writeUpdatedUser answers an update with the user and its new ETag, or
+// with the error
+func writeUpdatedUser(w http.ResponseWriter, r *http.Request, user *models.User, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			writeJSONError(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrVersionConflict):
+			writeJSONError(w, "The user was changed by someone else; reload it and try again", http.StatusPreconditionFailed)
+		case errors.Is(err, repository.ErrEmailTaken):
+			writeJSONError(w, "A user with this email already exists", http.StatusConflict)
+		case errors.Is(err, auth.ErrInvalidEmail):
+			writeJSONError(w, "Invalid email address", http.StatusBadRequest)
+		default:
+			logging.FromContext(r.Context()).Error("Failed to update user", "err", err)
+			writeJSONError(w, "Failed to update user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(user.Version))
 	json.NewEncoder(w).Encode(user)
 }
 
-// DeleteUser handles deleting a user
+// etag is the entity tag for a version of a user
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion reads the version named by the If-Match header. It returns
+// nil when there is no header or it is "*", and ok is false when it names
+// no version of this user, which can then never match.
+func ifMatchVersion(r *http.Request) (version *int, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+	// Weak tags are accepted: the version changes with every update anyway
+	tag := strings.TrimPrefix(header, "W/")
+	v, err := strconv.Atoi(strings.Trim(tag, `"`))
+	if err != nil || len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return nil, false
+	}
+	return &v, true
+}
+
+// SetUserRole handles PATCH /users/{id}/role with a {"role": "..."} body.
+// Only admins can change roles, and each change is recorded in the audit log.
+func SetUserRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	defer r.Body.Close()
+
+	userinfo := requireAdmin(w, r, models.ScopeWrite)
+	if userinfo == nil {
+		return
+	}
+	actorID, err := strconv.Atoi(userinfo.ID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid session"}`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Role string `json:"role"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&payload); err != nil {
+		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+		return
+	}
+	role := strings.ToUpper(strings.TrimSpace(payload.Role))
+	if !models.IsValidRole(role) {
+		http.Error(w, `{"error": "Role must be ADMIN, SUPPORT or USER"}`, http.StatusBadRequest)
+		return
+	}
+
+	previous, err := repository.SetUserRole(r.Context(), id, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			writeJSONError(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrLastAdmin):
+			writeJSONError(w, "Cannot remove the ADMIN role from the last administrator", http.StatusConflict)
+		default:
+			logging.FromContext(r.Context()).Error("Failed to set user role", "user_id", id, "err", err)
+			writeJSONError(w, "Failed to set user role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if previous != role {
+		err = repository.RecordAudit(r.Context(), &models.AuditEntry{
+			ActorID:   &actorID,
+			SubjectID: &id,
+			Action:    models.AuditRoleChanged,
+			Metadata:  map[string]interface{}{"from": previous, "to": role, "source": "rest"},
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to record role change", "user_id", id, "err", err)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "role": role, "previous_role": previous})
+}
+
+// DeleteUser handles DELETE /users/{id}. Only admins can delete users, and
+// the last administrator cannot be deleted.
 func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	userinfo := requireAdmin(w, r, models.ScopeWrite)
+	if userinfo == nil {
+		return
+	}
+	actorID, err := strconv.Atoi(userinfo.ID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid session"}`, http.StatusUnauthorized)
+		return
+	}
+
 	vars := mux.Vars(r)
 	idStr, ok := vars["id"]
 	if !ok {
@@ -140,9 +461,16 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := repository.DeleteUser(id); err != nil {
-		log.Printf("Failed to delete user: %v", err)
-		http.Error(w, `{"error": "Failed to delete user"}`, http.StatusInternalServerError)
+	if err := repository.DeleteUser(r.Context(), id, &actorID, "rest"); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			writeJSONError(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrLastAdmin):
+			writeJSONError(w, "Cannot delete the last administrator", http.StatusConflict)
+		default:
+			logging.FromContext(r.Context()).Error("Failed to delete user", "err", err)
+			http.Error(w, `{"error": "Failed to delete user"}`, http.StatusInternalServerError)
+		}
 		return
 	}
 
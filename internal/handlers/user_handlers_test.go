@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-management-service/internal/auth"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+func TestParseMergePatch(t *testing.T) {
+	patch, err := parseMergePatch(strings.NewReader(`{"email": "jane@example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch.Name != nil || patch.Email == nil || *patch.Email != "jane@example.com" {
+		t.Errorf("expected only the email to change, got %+v", patch)
+	}
+
+	for _, body := range []string{
+		`{"name": null}`,
+		`{"name": " "}`,
+		`{"email": 42}`,
+		`{"role": "ADMIN"}`,
+		`{"version": 2}`,
+		`["name"]`,
+		`null`,
+	} {
+		if _, err := parseMergePatch(strings.NewReader(body)); err == nil {
+			t.Errorf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int
+		ok      bool
+	}{
+		{"", 0, true},
+		{"*", 0, true},
+		{`"3"`, 3, true},
+		{`W/"3"`, 3, true},
+		{`3`, 0, false},
+		{`"abc"`, 0, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("PATCH", "/users/1", nil)
+		if tt.header != "" {
+			req.Header.Set("If-Match", tt.header)
+		}
+		version, ok := ifMatchVersion(req)
+		if ok != tt.ok || (version == nil) != (tt.version == 0) || (version != nil && *version != tt.version) {
+			t.Errorf("If-Match %q: got %v, %v", tt.header, version, ok)
+		}
+	}
+}
+
+func TestRequireSelfOrAdmin(t *testing.T) {
+	tests := []struct {
+		name string
+		user *middleware.User
+		want int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"self", &middleware.User{ID: "7", Role: models.RoleUser}, http.StatusOK},
+		{"other user", &middleware.User{ID: "8", Role: models.RoleUser}, http.StatusForbidden},
+		{"admin", &middleware.User{ID: "1", Role: models.RoleAdmin}, http.StatusOK},
+		{"read-only key", &middleware.User{ID: "7", Role: models.RoleUser, Scopes: []string{models.ScopeRead}}, http.StatusForbidden},
+		{"impersonated", &middleware.User{ID: "7", Role: models.RoleUser, Actor: &auth.Actor{Subject: "1"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("PATCH", "/users/7", nil)
+		if tt.user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserCtxKey, tt.user))
+		}
+		rec := httptest.NewRecorder()
+		if requireSelfOrAdmin(rec, req, 7) == nil && tt.want == http.StatusOK {
+			t.Errorf("%s: refused with %d", tt.name, rec.Code)
+		} else if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestDeleteUserRequiresAdmin(t *testing.T) {
+	tests := []struct {
+		name string
+		user *middleware.User
+		want int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"self", &middleware.User{ID: "7", Role: models.RoleUser}, http.StatusForbidden},
+		{"support", &middleware.User{ID: "8", Role: models.RoleSupport}, http.StatusForbidden},
+		{"read-only admin key", &middleware.User{ID: "1", Role: models.RoleAdmin, Scopes: []string{models.ScopeRead}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("DELETE", "/users/7", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "7"})
+		if tt.user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserCtxKey, tt.user))
+		}
+		rec := httptest.NewRecorder()
+		DeleteUser(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"user-management-service/internal/database"
+)
+
+// PostgresPing checks that the pool can reach the database
+func PostgresPing(ctx context.Context) error {
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+	return database.DB.Ping(ctx)
+}
+
+// ReplicaPing checks that the read replica is reachable. Reads fall back to
+// the primary when it is not, so this is registered as optional.
+func ReplicaPing(ctx context.Context) error {
+	if database.Replica == nil {
+		return errors.New("read replica is not configured")
+	}
+	return database.Replica.Ping(ctx)
+}
+
+// PoolSaturation fails when at least maxRatio of the pool's connections are in use
+func PoolSaturation(maxRatio float64) CheckFunc {
+	return func(ctx context.Context) error {
+		if database.DB == nil {
+			return errors.New("database connection is not initialized")
+		}
+
+		stat := database.DB.Stat()
+		if stat.MaxConns() == 0 {
+			return nil
+		}
+		ratio := float64(stat.AcquiredConns()) / float64(stat.MaxConns())
+		if ratio >= maxRatio {
+			return fmt.Errorf("pool saturated: %d of %d connections in use", stat.AcquiredConns(), stat.MaxConns())
+		}
+		return nil
+	}
+}
+
+// MigrationVersion fails when the schema is not at the version the code expects
+func MigrationVersion(expected string) CheckFunc {
+	return func(ctx context.Context) error {
+		version, err := database.SchemaVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("reading schema version: %v", err)
+		}
+		if version != expected {
+			return fmt.Errorf("schema at %q, expected %q (run cmd/migrate)", version, expected)
+		}
+		return nil
+	}
+}
+
+// TCPReachable checks that a TCP connection can be opened, e.g. to the SMTP server
+func TCPReachable(addr string) CheckFunc {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,162 @@
+// Package health serves liveness and readiness probes backed by a registry
+// of dependency checks.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckFunc reports a dependency as unhealthy by returning an error
+type CheckFunc func(ctx context.Context) error
+
+// Check is a named readiness check
+type Check struct {
+	Name string
+	// Optional checks are reported but do not fail readiness
+	Optional bool
+	Timeout  time.Duration
+	Func     CheckFunc
+}
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Status     string `json:"status"`
+	Optional   bool   `json:"optional,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the readiness response body
+type Report struct {
+	Status    string                 `json:"status"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Status values
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Registry runs readiness checks. Results are cached for cacheTTL so
+// frequent probes from several load balancers do not hammer dependencies.
+type Registry struct {
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	checks   []Check
+	cached   *Report
+	cachedAt time.Time
+
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry(cacheTTL time.Duration) *Registry {
+	return &Registry{cacheTTL: cacheTTL}
+}
+
+// Register adds a check. A zero Timeout defaults to two seconds.
+func (r *Registry) Register(check Check) {
+	if check.Timeout == 0 {
+		check.Timeout = 2 * time.Second
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check)
+	r.cached = nil
+}
+
+// SetShuttingDown makes readiness fail so traffic is drained away before the server stops
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Ready runs (or returns cached) readiness checks
+func (r *Registry) Ready(ctx context.Context) Report {
+	if r.shuttingDown.Load() {
+		return Report{Status: StatusShuttingDown, CheckedAt: time.Now()}
+	}
+
+	// Holding the lock while checking also collapses concurrent probes into one run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cached != nil && time.Since(r.cachedAt) < r.cacheTTL {
+		return *r.cached
+	}
+
+	report := Report{Status: StatusOK, CheckedAt: time.Now(), Checks: make(map[string]CheckResult, len(r.checks))}
+	results := make([]CheckResult, len(r.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range r.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for i, check := range r.checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != StatusOK && !check.Optional {
+			report.Status = StatusFail
+		}
+	}
+
+	r.cached = &report
+	r.cachedAt = time.Now()
+	return report
+}
+
+func run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Func(ctx)
+	result := CheckResult{
+		Status:     StatusOK,
+		Optional:   check.Optional,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Livez reports that the process is up. It deliberately checks no
+// dependencies: a database outage should not get every replica restarted.
+func (r *Registry) Livez(w http.ResponseWriter, req *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOK, CheckedAt: time.Now()})
+}
+
+// Readyz reports whether the instance should receive traffic
+func (r *Registry) Readyz(w http.ResponseWriter, req *http.Request) {
+	report := r.Ready(req.Context())
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
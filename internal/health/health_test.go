@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadyzReportsFailingCheck(t *testing.T) {
+	r := NewRegistry(0)
+	r.Register(Check{Name: "postgres", Func: func(ctx context.Context) error { return errors.New("connection refused") }})
+	r.Register(Check{Name: "smtp", Optional: true, Func: func(ctx context.Context) error { return errors.New("timeout") }})
+
+	w := httptest.NewRecorder()
+	r.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	report := r.Ready(context.Background())
+	if got := report.Checks["postgres"]; got.Status != StatusFail || got.Error != "connection refused" {
+		t.Errorf("postgres result = %+v", got)
+	}
+}
+
+func TestOptionalCheckDoesNotFailReadiness(t *testing.T) {
+	r := NewRegistry(0)
+	r.Register(Check{Name: "postgres", Func: func(ctx context.Context) error { return nil }})
+	r.Register(Check{Name: "smtp", Optional: true, Func: func(ctx context.Context) error { return errors.New("timeout") }})
+
+	report := r.Ready(context.Background())
+	if report.Status != StatusOK {
+		t.Errorf("status = %s, want ok", report.Status)
+	}
+	if report.Checks["smtp"].Status != StatusFail {
+		t.Errorf("optional failure should still be reported: %+v", report.Checks["smtp"])
+	}
+}
+
+func TestResultsAreCached(t *testing.T) {
+	var calls atomic.Int32
+	r := NewRegistry(time.Minute)
+	r.Register(Check{Name: "postgres", Func: func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+
+	for i := 0; i < 5; i++ {
+		r.Ready(context.Background())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("check ran %d times, want 1", n)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	r := NewRegistry(0)
+	r.Register(Check{Name: "slow", Timeout: 10 * time.Millisecond, Func: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	if report := r.Ready(context.Background()); report.Status != StatusFail {
+		t.Errorf("status = %s, want fail", report.Status)
+	}
+}
+
+func TestShuttingDownFailsReadinessButNotLiveness(t *testing.T) {
+	r := NewRegistry(0)
+	r.Register(Check{Name: "postgres", Func: func(ctx context.Context) error { return nil }})
+	r.SetShuttingDown()
+
+	ready := httptest.NewRecorder()
+	r.Readyz(ready, httptest.NewRequest("GET", "/readyz", nil))
+	if ready.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz status = %d, want 503", ready.Code)
+	}
+
+	live := httptest.NewRecorder()
+	r.Livez(live, httptest.NewRequest("GET", "/livez", nil))
+	if live.Code != http.StatusOK {
+		t.Errorf("livez status = %d, want 200", live.Code)
+	}
+}
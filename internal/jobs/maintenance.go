@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"user-management-service/internal/config"
+	"user-management-service/internal/privacy"
+	"user-management-service/internal/repository"
+)
+
+// jobRunRetention is how long the history of job runs is kept
+const jobRunRetention = 30 * 24 * time.Hour
+
+// Maintenance returns the service's maintenance jobs
+func Maintenance(cfg *config.Config) []Job {
+	return []Job{
+		{
+			// Codes nobody tried are of no further use once expired
+			Name: "purge-expired-otps",
+			Spec: "*/15 * * * *",
+			Run: func(ctx context.Context) (int64, error) {
+				return repository.PurgeUntriedOTPs(ctx, time.Now())
+			},
+		},
+		{
+			// Tried and used codes are the record of login attempts
+			Name: "purge-login-attempts",
+			Spec: "30 3 * * *",
+			Run: func(ctx context.Context) (int64, error) {
+				return repository.PurgeOTPs(ctx, time.Now().Add(-cfg.LoginAttemptRetention))
+			},
+		},
+		{
+			Name: "purge-expired-sessions",
+			Spec: "0 * * * *",
+			Run: func(ctx context.Context) (int64, error) {
+				return repository.PurgeSessions(ctx, time.Now())
+			},
+		},
+		{
+			Name: "erase-deleted-accounts",
+			Spec: "10 * * * *",
+			Run: func(ctx context.Context) (int64, error) {
+				erased, err := privacy.EraseDue(ctx)
+				return int64(erased), err
+			},
+		},
+		{
+			Name: "purge-job-runs",
+			Spec: "45 4 * * *",
+			Run: func(ctx context.Context) (int64, error) {
+				return repository.PurgeJobRuns(ctx, time.Now().Add(-jobRunRetention))
+			},
+		},
+	}
+}
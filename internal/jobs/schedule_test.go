@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, 10, 21, 10, 7, 30, 0, time.UTC)
+
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 21, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 21, 10, 15, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 21, 11, 0, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2026, 10, 22, 3, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2026, 10, 21, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * 6", time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@every 10m", time.Date(2026, 10, 21, 10, 10, 0, 0, time.UTC)},
+		{"@every 90s", time.Date(2026, 10, 21, 10, 9, 0, 0, time.UTC)},
+	} {
+		schedule, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: expected %v, got %v", tc.spec, tc.want, got)
+		}
+	}
+}
+
+func TestScheduleNextIsStrictlyAfter(t *testing.T) {
+	schedule, err := ParseSchedule("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 21, 10, 0, 0, 0, time.UTC)
+	if got := schedule.Next(at); !got.Equal(at.Add(time.Hour)) {
+		t.Errorf("expected the following hour, got %v", got)
+	}
+}
+
+func TestParseScheduleRejectsInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 1ms", "@every soon", "@yearly"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package jobs runs periodic maintenance. Every instance runs the
+// scheduler; an advisory lock and the run history in job_runs make sure
+// each scheduled run happens on only one of them.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"user-management-service/internal/metrics"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// Job is a task run on a schedule
+type Job struct {
+	Name string
+	// Spec is the schedule, see ParseSchedule
+	Spec string
+	// Run does the work and returns how many rows it removed or changed
+	Run func(ctx context.Context) (int64, error)
+
+	schedule Schedule
+}
+
+// Next returns when the job is next due after t
+func (j *Job) Next(t time.Time) time.Time {
+	return j.schedule.Next(t)
+}
+
+// ErrUnknownJob is returned by RunNow for a job that is not scheduled
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrJobBusy is returned by RunNow when the job is running elsewhere
+var ErrJobBusy = errors.New("job is already running")
+
+// Scheduler runs jobs at their scheduled times
+type Scheduler struct {
+	jobs   []*Job
+	runner string
+}
+
+// NewScheduler parses the schedules of jobs
+func NewScheduler(jobs []Job) (*Scheduler, error) {
+	runner, _ := os.Hostname()
+	s := &Scheduler{runner: runner}
+	for _, j := range jobs {
+		schedule, err := ParseSchedule(j.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %v", j.Name, err)
+		}
+		j.schedule = schedule
+		s.jobs = append(s.jobs, &j)
+	}
+	return s, nil
+}
+
+// Jobs lists the scheduled jobs
+func (s *Scheduler) Jobs() []*Job {
+	return s.jobs
+}
+
+// Run runs every job at its scheduled times until ctx is cancelled, then
+// waits for runs in progress to stop
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *Job) {
+	next := j.Next(time.Now())
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		if _, err := s.run(ctx, j, next); err != nil && !errors.Is(err, ErrJobBusy) {
+			slog.Error("Scheduled job could not start", "job", j.Name, "err", err)
+		}
+
+		// Skip the times missed while running rather than catching up
+		if now := time.Now(); now.After(next) {
+			next = now
+		}
+		next = j.Next(next)
+	}
+}
+
+// RunNow runs the named job immediately, unless it is running elsewhere,
+// and returns the recorded run
+func (s *Scheduler) RunNow(ctx context.Context, name string) (*models.JobRun, error) {
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return s.run(ctx, j, time.Now().UTC().Truncate(time.Microsecond))
+		}
+	}
+	return nil, ErrUnknownJob
+}
+
+// run runs j for the given scheduled time. It returns a nil run when
+// another instance already ran it for that time, and ErrJobBusy when one
+// is running it now.
+func (s *Scheduler) run(ctx context.Context, j *Job, scheduledAt time.Time) (*models.JobRun, error) {
+	unlock, ok, err := repository.TryJobLock(ctx, j.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrJobBusy
+	}
+	defer unlock()
+
+	run := &models.JobRun{Job: j.Name, ScheduledAt: scheduledAt, Runner: s.runner}
+	started, err := repository.StartJobRun(ctx, run)
+	if err != nil || !started {
+		return nil, err
+	}
+
+	start := time.Now()
+	run.Affected, err = safeRun(ctx, j)
+	run.Status = models.JobSucceeded
+	if err != nil {
+		run.Status = models.JobFailed
+		run.Error = err.Error()
+		slog.Error("Scheduled job failed", "job", j.Name, "err", err)
+	} else {
+		slog.Info("Scheduled job finished", "job", j.Name, "affected", run.Affected, "duration", time.Since(start).String())
+	}
+	metrics.JobRun(j.Name, strings.ToLower(run.Status), time.Since(start))
+
+	// Record the outcome even when shutting down cut the run short
+	if err := repository.FinishJobRun(context.WithoutCancel(ctx), run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// safeRun runs j, turning a panic into an error so one faulty job does not
+// bring the service down
+func safeRun(ctx context.Context, j *Job) (affected int64, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return j.Run(ctx)
+}
@@ -0,0 +1,89 @@
+package loaders
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadBatchesConcurrentCalls(t *testing.T) {
+	var calls atomic.Int32
+	var batchSize int
+	l := NewLoader(context.Background(), func(ctx context.Context, keys []int) (map[int]string, error) {
+		calls.Add(1)
+		batchSize = len(keys)
+		values := make(map[int]string)
+		for _, k := range keys {
+			if k%10 != 0 { // every tenth key is missing
+				values[k] = "user"
+			}
+		}
+		return values, nil
+	}, 50*time.Millisecond, 0)
+
+	var wg sync.WaitGroup
+	var missing atomic.Int32
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			// Ask for every key twice; the duplicate must come from the cache
+			for j := 0; j < 2; j++ {
+				v, err := l.Load(context.Background(), id)
+				if err != nil {
+					t.Error(err)
+				}
+				if v == "" && j == 0 {
+					missing.Add(1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fetch ran %d times, want 1", n)
+	}
+	if batchSize != 100 {
+		t.Errorf("batch had %d keys, want 100", batchSize)
+	}
+	if n := missing.Load(); n != 10 {
+		t.Errorf("%d keys resolved to the zero value, want 10", n)
+	}
+}
+
+func TestLoadSplitsFullBatches(t *testing.T) {
+	var calls atomic.Int32
+	l := NewLoader(context.Background(), func(ctx context.Context, keys []int) (map[int]int, error) {
+		calls.Add(1)
+		return nil, nil
+	}, time.Hour, 25)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			l.Load(context.Background(), id)
+		}(i)
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 4 {
+		t.Errorf("fetch ran %d times, want 4 batches of 25", n)
+	}
+}
+
+func TestLoadReturnsFetchError(t *testing.T) {
+	boom := errors.New("boom")
+	l := NewLoader(context.Background(), func(ctx context.Context, keys []string) (map[string]int, error) {
+		return nil, boom
+	}, time.Millisecond, 0)
+
+	if _, err := l.Load(context.Background(), "a"); !errors.Is(err, boom) {
+		t.Errorf("err = %v, want %v", err, boom)
+	}
+}
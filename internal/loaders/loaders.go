@@ -0,0 +1,113 @@
+// Package loaders batches the per-row lookups GraphQL resolvers make, so a
+// list of N items referencing users costs one query instead of N.
+package loaders
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const (
+	// batchWait is how long a loader collects keys before fetching them
+	batchWait = 2 * time.Millisecond
+	// maxBatch caps the size of one ANY($1) array
+	maxBatch = 500
+)
+
+// Loaders are the per-request dataloaders
+type Loaders struct {
+	UserByID    *Loader[int, *models.User]
+	UserByEmail *Loader[string, *models.User]
+	// EffectiveRoles resolves users' roles including those from groups
+	EffectiveRoles *Loader[int, []string]
+}
+
+// New creates a fresh set of loaders. Their caches live as long as the
+// returned value, so create one per request.
+func New(ctx context.Context) *Loaders {
+	return &Loaders{
+		UserByID:       NewLoader(ctx, repository.GetUsersByIDs, batchWait, maxBatch),
+		UserByEmail:    NewLoader(ctx, repository.GetUsersByEmails, batchWait, maxBatch),
+		EffectiveRoles: NewLoader(ctx, repository.GetEffectiveRoles, batchWait, maxBatch),
+	}
+}
+
+type loadersKey struct{}
+
+// Middleware installs a new set of loaders in each request's context
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withLoaders(r.Context())))
+	})
+}
+
+func withLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, New(ctx))
+}
+
+// GraphQLExtension gives every subscription event its own loaders. A
+// WebSocket connection is a single HTTP request, so the loaders installed
+// by Middleware would otherwise cache users and roles for as long as the
+// connection stays open.
+type GraphQLExtension struct{}
+
+var (
+	_ graphql.HandlerExtension    = GraphQLExtension{}
+	_ graphql.ResponseInterceptor = GraphQLExtension{}
+)
+
+// ExtensionName implements graphql.HandlerExtension
+func (GraphQLExtension) ExtensionName() string { return "Loaders" }
+
+// Validate implements graphql.HandlerExtension
+func (GraphQLExtension) Validate(graphql.ExecutableSchema) error { return nil }
+
+// InterceptResponse installs fresh loaders before each subscription event
+// is resolved
+func (GraphQLExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if graphql.HasOperationContext(ctx) {
+		if op := graphql.GetOperationContext(ctx).Operation; op != nil && op.Operation == ast.Subscription {
+			ctx = withLoaders(ctx)
+		}
+	}
+	return next(ctx)
+}
+
+// For returns the request's loaders. Outside a request, e.g. in tests, it
+// returns uncached loaders so callers need not special-case it.
+func For(ctx context.Context) *Loaders {
+	if l, ok := ctx.Value(loadersKey{}).(*Loaders); ok {
+		return l
+	}
+	return New(ctx)
+}
+
+// GetUser loads a user by ID, returning nil if there is none
+func GetUser(ctx context.Context, id int) (*models.User, error) {
+	return For(ctx).UserByID.Load(ctx, id)
+}
+
+// GetEffectiveRoles loads a user's effective roles. A user deleted since
+// it was loaded keeps the role it was loaded with.
+func GetEffectiveRoles(ctx context.Context, user *models.User) ([]string, error) {
+	roles, err := For(ctx).EffectiveRoles.Load(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		return []string{user.Role}, nil
+	}
+	return roles, nil
+}
+
+// GetUserByEmail loads a user by email, returning nil if there is none
+func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return For(ctx).UserByEmail.Load(ctx, email)
+}
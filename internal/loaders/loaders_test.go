@@ -0,0 +1,36 @@
+package loaders
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestSubscriptionEventsGetFreshLoaders(t *testing.T) {
+	for _, tc := range []struct {
+		op    ast.Operation
+		fresh bool
+	}{
+		{ast.Subscription, true},
+		{ast.Query, false},
+	} {
+		ctx := withLoaders(context.Background())
+		ctx = graphql.WithOperationContext(ctx, &graphql.OperationContext{
+			Operation: &ast.OperationDefinition{Operation: tc.op},
+		})
+
+		var seen []*Loaders
+		for i := 0; i < 2; i++ {
+			GraphQLExtension{}.InterceptResponse(ctx, func(ctx context.Context) *graphql.Response {
+				seen = append(seen, For(ctx))
+				return nil
+			})
+		}
+
+		if fresh := seen[0] != For(ctx) && seen[0] != seen[1]; fresh != tc.fresh {
+			t.Errorf("%s: fresh loaders = %v, want %v", tc.op, fresh, tc.fresh)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"otp":           true,
+	"code":          true,
+	"token":         true,
+	"id_token":      true,
+	"access_token":  true,
+	"refresh_token": true,
+	"api_key":       true,
+	"password":      true,
+	"secret":        true,
+	"client_secret": true,
+	"authorization": true,
+}
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+	// JWTs are three base64url segments, the first always starting with eyJ
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+	apiKeyPattern = regexp.MustCompile(`ums_[0-9a-f]{12}_[A-Za-z0-9_-]+`)
+	bearerPattern = regexp.MustCompile(`(?i)(Bearer|ApiKey)\s+\S+`)
+)
+
+// Redact is a slog ReplaceAttr function. Values under sensitive keys are
+// dropped entirely; emails, JWTs and API keys embedded in any other string,
+// including the message and error text, are masked.
+func Redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, RedactString(err.Error()))
+		}
+	}
+	return a
+}
+
+// RedactString masks emails (keeping the first character and the domain),
+// JWTs and API keys in s
+func RedactString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = apiKeyPattern.ReplaceAllString(s, redacted)
+	s = bearerPattern.ReplaceAllString(s, "$1 "+redacted)
+	return emailPattern.ReplaceAllString(s, "$1***@$2")
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-management-service/internal/database"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPMiddleware records request counts and latency per route template. It
+// must be installed with Router.Use so the matched route is known.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		m := httpsnoop.CaptureMetrics(next, w, r)
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(m.Code)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(m.Duration.Seconds())
+	})
+}
+
+// GraphQLExtension is a gqlgen extension recording per-operation and
+// per-resolver timings. Operation names are chosen by clients, so only the
+// names in Operations, those of the persisted query allow-list, become
+// label values; every other named operation is recorded as "other".
+type GraphQLExtension struct {
+	Operations map[string]bool
+}
+
+var (
+	_ graphql.HandlerExtension    = GraphQLExtension{}
+	_ graphql.ResponseInterceptor = GraphQLExtension{}
+	_ graphql.FieldInterceptor    = GraphQLExtension{}
+)
+
+// ExtensionName implements graphql.HandlerExtension
+func (GraphQLExtension) ExtensionName() string { return "Metrics" }
+
+// Validate implements graphql.HandlerExtension
+func (GraphQLExtension) Validate(graphql.ExecutableSchema) error { return nil }
+
+// InterceptResponse times each operation
+func (e GraphQLExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+
+	oc := graphql.GetOperationContext(ctx)
+	start := oc.Stats.OperationStart
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	resp := next(ctx)
+
+	name, opType := e.operationLabels(oc)
+	status := "ok"
+	if resp == nil || len(resp.Errors) > 0 {
+		status = "error"
+	}
+	graphqlOperations.WithLabelValues(name, opType, status).Inc()
+	graphqlDuration.WithLabelValues(name, opType).Observe(time.Since(start).Seconds())
+	return resp
+}
+
+// InterceptField times fields that are backed by resolver code
+func (GraphQLExtension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	start := time.Now()
+	res, err := next(ctx)
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	resolverDuration.WithLabelValues(fc.Object+"."+fc.Field.Name, status).Observe(time.Since(start).Seconds())
+	return res, err
+}
+
+func (e GraphQLExtension) operationLabels(oc *graphql.OperationContext) (name, opType string) {
+	name, opType = oc.OperationName, "unknown"
+	if oc.Operation != nil {
+		opType = string(oc.Operation.Operation)
+		if name == "" {
+			name = oc.Operation.Name
+		}
+	}
+	switch {
+	case name == "":
+		name = "anonymous"
+	case !e.Operations[name]:
+		name = "other"
+	}
+	return name, opType
+}
+
+// poolCollector exports pgxpool statistics at scrape time
+type poolCollector struct{}
+
+var (
+	poolAcquired = prometheus.NewDesc(namespace+"_db_pool_acquired_connections", "Connections currently in use.", nil, nil)
+	poolIdle     = prometheus.NewDesc(namespace+"_db_pool_idle_connections", "Idle connections in the pool.", nil, nil)
+	poolTotal    = prometheus.NewDesc(namespace+"_db_pool_total_connections", "Open connections in the pool.", nil, nil)
+	poolMax      = prometheus.NewDesc(namespace+"_db_pool_max_connections", "Maximum pool size.", nil, nil)
+	poolAcquires = prometheus.NewDesc(namespace+"_db_pool_acquires_total", "Successful connection acquires.", nil, nil)
+	poolEmpty    = prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total", "Acquires that had to wait for a connection.", nil, nil)
+	poolWait     = prometheus.NewDesc(namespace+"_db_pool_acquire_wait_seconds_total", "Total time spent acquiring connections.", nil, nil)
+)
+
+// Describe implements prometheus.Collector
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{poolAcquired, poolIdle, poolTotal, poolMax, poolAcquires, poolEmpty, poolWait} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.DB == nil {
+		return
+	}
+
+	stat := database.DB.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmpty, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolWait, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
@@ -0,0 +1,164 @@
+// Package metrics defines the service's Prometheus metrics and the
+// instrumentation that records them.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ums"
+
+// Registry holds every metric exported by the service
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	graphqlOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_operations_total",
+		Help:      "GraphQL operations by operation name, type and outcome.",
+	}, []string{"operation", "type", "status"})
+
+	graphqlDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_duration_seconds",
+		Help:      "GraphQL operation latency by operation name and type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "type"})
+
+	resolverDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_resolver_duration_seconds",
+		Help:      "Latency of GraphQL field resolvers (fields backed by resolver code).",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"field", "status"})
+
+	graphqlRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_rejected_total",
+		Help:      "GraphQL operations rejected before execution, by reason (complexity, depth, not_allowlisted).",
+	}, []string{"reason"})
+
+	graphqlComplexity = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_complexity",
+		Help:      "Calculated cost of GraphQL operations, for tuning GRAPHQL_MAX_COMPLEXITY.",
+		Buckets:   prometheus.ExponentialBuckets(10, 2, 12),
+	})
+
+	graphqlDepth = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_depth",
+		Help:      "Selection depth of GraphQL operations, for tuning GRAPHQL_MAX_DEPTH.",
+		Buckets:   prometheus.LinearBuckets(1, 1, 15),
+	})
+
+	otpIssued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "otp_issued_total",
+		Help:      "OTPs generated and stored.",
+	})
+
+	otpVerified = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "otp_verified_total",
+		Help:      "OTPs successfully verified.",
+	})
+
+	otpFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "otp_failed_total",
+		Help:      "OTP verifications rejected, by reason.",
+	}, []string{"reason"})
+
+	emailsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_send_total",
+		Help:      "Email deliveries by outcome (sent, failed, demo).",
+	}, []string{"outcome"})
+
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_verification_failures_total",
+		Help:      "Rejected credentials by credential type (jwt, api_key) and reason.",
+	}, []string{"credential", "reason"})
+
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Scheduled job runs on this instance, by job and status (succeeded, failed).",
+	}, []string{"job", "status"})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_run_duration_seconds",
+		Help:      "Duration of scheduled job runs on this instance.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"job"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		poolCollector{},
+		httpRequests, httpDuration,
+		graphqlOperations, graphqlDuration, resolverDuration,
+		graphqlRejected, graphqlComplexity, graphqlDepth,
+		otpIssued, otpVerified, otpFailed,
+		emailsSent, authFailures,
+		jobRuns, jobDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// GraphQLRejected records an operation refused before execution
+func GraphQLRejected(reason string) { graphqlRejected.WithLabelValues(reason).Inc() }
+
+// GraphQLCost records the complexity and depth of an operation
+func GraphQLCost(complexity, depth int) {
+	graphqlComplexity.Observe(float64(complexity))
+	graphqlDepth.Observe(float64(depth))
+}
+
+// OTPIssued records a newly issued OTP
+func OTPIssued() { otpIssued.Inc() }
+
+// OTPVerified records a successful OTP verification
+func OTPVerified() { otpVerified.Inc() }
+
+// OTPFailed records a rejected OTP verification
+func OTPFailed(reason string) { otpFailed.WithLabelValues(reason).Inc() }
+
+// EmailSent records the outcome of an email delivery
+func EmailSent(outcome string) { emailsSent.WithLabelValues(outcome).Inc() }
+
+// AuthFailure records a rejected JWT or API key
+func AuthFailure(credential, reason string) { authFailures.WithLabelValues(credential, reason).Inc() }
+
+// JobRun records a finished run of a scheduled job
+func JobRun(job, status string, d time.Duration) {
+	jobRuns.WithLabelValues(job, status).Inc()
+	jobDuration.WithLabelValues(job).Observe(d.Seconds())
+}
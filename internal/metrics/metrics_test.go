@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestHTTPMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(HTTPMiddleware)
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/"+id, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("/users/{id}", "GET", "404")); got != 3 {
+		t.Errorf("requests for /users/{id} = %v, want 3", got)
+	}
+}
+
+func TestRegistryGathers(t *testing.T) {
+	OTPFailed("expired")
+	if _, err := Registry.Gather(); err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+}
+
+func TestOperationLabelsOnlyUseKnownNames(t *testing.T) {
+	e := GraphQLExtension{Operations: map[string]bool{"Me": true}}
+	for _, tc := range []struct{ sent, want string }{
+		{"Me", "Me"},
+		{"Attacker123", "other"},
+		{"", "anonymous"},
+	} {
+		oc := &graphql.OperationContext{
+			OperationName: tc.sent,
+			Operation:     &ast.OperationDefinition{Operation: ast.Query, Name: tc.sent},
+		}
+		if name, opType := e.operationLabels(oc); name != tc.want || opType != "query" {
+			t.Errorf("%q: got %s %s, want %s query", tc.sent, name, opType, tc.want)
+		}
+	}
+}
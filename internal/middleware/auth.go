@@ -2,10 +2,19 @@ package middleware
 
 import (
 	"context"
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 	"user-management-service/internal/auth"
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/metrics"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type contextKey struct {
@@ -19,43 +28,297 @@ type User struct {
 	ID    string
 	Email string
 	Role  string
+
+	// Roles are the user's effective roles: Role as currently stored plus
+	// those granted through groups
+	Roles []string
+
+	// Set when the request authenticated with an API key
+	APIKeyID int
+	Scopes   []string
+
+	// Set when the request authenticated with a JWT
+	SessionID string
+	ExpiresAt time.Time
+	// Set when the JWT is an OAuth access token: the scopes the user
+	// granted the client
+	OAuthScopes []string
+
+	// Set when an admin or support user is impersonating this user
+	Actor                  *auth.Actor
+	ImpersonationSessionID int
+}
+
+// IsImpersonated reports whether the request is made by someone acting as the user
+func (u *User) IsImpersonated() bool {
+	return u.Actor != nil
+}
+
+// HasRole reports whether the user holds role, directly or through a group.
+// Users built without effective roles, as in tests, only hold Role.
+func (u *User) HasRole(role string) bool {
+	if u.Roles == nil {
+		return u.Role == role
+	}
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
 
-// AuthMiddleware extracts the user from the JWT in the Authorization header
+// HasPermission reports whether any of the user's roles grants permission
+func (u *User) HasPermission(permission string) bool {
+	if u.Roles == nil {
+		return models.RoleHasPermission(u.Role, permission)
+	}
+	return models.RolesHavePermission(u.Roles, permission)
+}
+
+// HasScope reports whether the credential allows scope. JWT sessions and
+// API keys created without scopes carry the full rights of the user.
+func (u *User) HasScope(scope string) bool {
+	if len(u.Scopes) == 0 {
+		return true
+	}
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthMiddleware extracts the user from the JWT in the Authorization header,
+// or from an API key sent as "Authorization: ApiKey <key>", "X-API-Key" or,
+// for clients such as SCIM that only speak bearer tokens, "Authorization:
+// Bearer <key>"
 func AuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			header := r.Header.Get("Authorization")
-
-			// Allow unauthenticated users in (resolvers will check for user later)
-			if header == "" {
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			// Validate the token
-			tokenStr := strings.TrimPrefix(header, "Bearer ")
-			claims, err := auth.VerifyJWT(tokenStr)
+			// Invalid credentials are treated like none: resolvers check for the user later
+			ctx, err := Authenticate(r.Context(), r.Header.Get("Authorization"), r.Header.Get("X-API-Key"))
 			if err != nil {
-				log.Printf("Auth Error: JWT verification failed: %v", err)
 				next.ServeHTTP(w, r)
 				return
 			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-			// Put user info into context
-			user := &User{
-				ID:    claims.UserID,
-				Email: claims.Email,
-				Role:  claims.Role,
-			}
+// Authenticate verifies a "Bearer <jwt>" or "ApiKey <key>" authorization
+// value, or a bare API key, and returns ctx carrying the user. Without
+// credentials ctx is returned unchanged. It is shared by the HTTP middleware
+// and the WebSocket handshake, where browsers send credentials in the
+// connection payload instead of headers.
+func Authenticate(ctx context.Context, authorization, apiKey string) (context.Context, error) {
+	logger := logging.FromContext(ctx)
 
-			ctx := context.WithValue(r.Context(), UserCtxKey, user)
+	if strings.HasPrefix(authorization, "ApiKey ") {
+		apiKey = strings.TrimPrefix(authorization, "ApiKey ")
+	} else if bearer := strings.TrimPrefix(authorization, "Bearer "); auth.IsAPIKey(bearer) {
+		apiKey = bearer
+	}
+	if apiKey != "" {
+		user, err := userForAPIKey(ctx, apiKey)
+		if err != nil {
+			logger.Warn("API key verification failed", "err", err)
+			metrics.AuthFailure("api_key", "invalid")
+			return ctx, err
+		}
+		if err := resolveRoles(ctx, user); err != nil {
+			logger.Error("Failed to resolve effective roles", "err", err)
+			return ctx, err
+		}
+		return withUser(ctx, user), nil
+	}
 
-			log.Printf("Auth Success: User %s with Role %s identified", user.Email, user.Role)
-			// Continue with the new context
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+	if authorization == "" {
+		return ctx, nil
 	}
+
+	return authenticateJWT(ctx, strings.TrimPrefix(authorization, "Bearer "), false)
+}
+
+// ErrWrongTokenType is returned for an OAuth access token presented to the
+// API, or a session token presented to the userinfo endpoint
+var ErrWrongTokenType = errors.New("token not valid for this endpoint")
+
+// AuthenticateAccessToken verifies a "Bearer <jwt>" OAuth access token, as
+// sent to the userinfo endpoint, and returns ctx carrying the user
+func AuthenticateAccessToken(ctx context.Context, authorization string) (context.Context, error) {
+	return authenticateJWT(ctx, strings.TrimPrefix(authorization, "Bearer "), true)
+}
+
+// authenticateJWT verifies a session token, or an OAuth access token when
+// accessToken is set. Access tokens carry only the scopes the user granted
+// a client, so the API must not take them for a session.
+func authenticateJWT(ctx context.Context, tokenStr string, accessToken bool) (context.Context, error) {
+	logger := logging.FromContext(ctx)
+
+	claims, err := auth.VerifyJWT(tokenStr)
+	if err != nil {
+		logger.Warn("JWT verification failed", "err", err)
+		metrics.AuthFailure("jwt", jwtFailureReason(err))
+		return ctx, err
+	}
+	if claims.IsAccessToken() != accessToken {
+		logger.Warn("JWT rejected", "err", ErrWrongTokenType, "access_token", claims.IsAccessToken())
+		metrics.AuthFailure("jwt", "wrong_token_type")
+		return ctx, ErrWrongTokenType
+	}
+
+	user := &User{
+		ID:    claims.UserID,
+		Email: claims.Email,
+		Role:  claims.Role,
+	}
+	if claims.ExpiresAt != nil {
+		user.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if claims.Scope != "" {
+		user.OAuthScopes = strings.Fields(claims.Scope)
+	}
+
+	if claims.Act != nil {
+		if err := checkImpersonation(ctx, claims); err != nil {
+			logger.Warn("Impersonation token rejected", "err", err)
+			metrics.AuthFailure("jwt", "impersonation_ended")
+			return ctx, err
+		}
+		user.Actor = claims.Act
+		user.ImpersonationSessionID, _ = strconv.Atoi(claims.ID)
+	} else if err := checkSession(ctx, claims); err != nil {
+		logger.Warn("Session rejected", "err", err)
+		metrics.AuthFailure("jwt", "session_revoked")
+		return ctx, err
+	} else {
+		user.SessionID = claims.ID
+	}
+
+	if err := resolveRoles(ctx, user); err != nil {
+		logger.Error("Failed to resolve effective roles", "err", err)
+		return ctx, err
+	}
+
+	return withUser(ctx, user), nil
+}
+
+// userForAPIKey resolves the owner of an API key. The role is read from the
+// users table so role changes apply to existing keys immediately.
+func userForAPIKey(ctx context.Context, key string) (*User, error) {
+	apiKey, err := auth.VerifyAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// A demotion must apply to the key at once, not when the replica catches up
+	owner, err := repository.GetUserByID(database.WithPrimary(ctx), apiKey.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, errors.New("api key owner no longer exists")
+	}
+
+	// The request may finish first; keep the trace but not its cancellation
+	touchCtx := context.WithoutCancel(ctx)
+	go func() {
+		if err := repository.TouchAPIKey(touchCtx, apiKey.ID); err != nil {
+			logging.FromContext(touchCtx).Warn("Failed to record API key use", "err", err)
+		}
+	}()
+
+	return &User{
+		ID:       strconv.Itoa(owner.ID),
+		Email:    owner.Email,
+		Role:     owner.Role,
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
+
+// resolveRoles loads the user's effective roles. They are looked up on
+// every request, like the session, so group changes apply immediately.
+func resolveRoles(ctx context.Context, user *User) error {
+	id, err := strconv.Atoi(user.ID)
+	if err != nil {
+		return err
+	}
+	roles, err := repository.GetEffectiveRoles(ctx, []int{id})
+	if err != nil {
+		return err
+	}
+	user.Roles = roles[id]
+	if user.Roles == nil {
+		return errors.New("user no longer exists")
+	}
+	return nil
+}
+
+// checkSession rejects tokens whose login session was revoked
+func checkSession(ctx context.Context, claims *auth.Claims) error {
+	if claims.ID == "" {
+		return errors.New("token has no session")
+	}
+
+	session, err := repository.GetSession(ctx, claims.ID)
+	if err != nil {
+		return err
+	}
+	if session == nil || !session.IsActive(time.Now()) {
+		return errors.New("session has been revoked")
+	}
+	return nil
+}
+
+// checkImpersonation rejects impersonation tokens whose session was ended early
+func checkImpersonation(ctx context.Context, claims *auth.Claims) error {
+	id, err := strconv.Atoi(claims.ID)
+	if err != nil {
+		return errors.New("impersonation token has no session")
+	}
+
+	session, err := repository.GetImpersonationSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.EndedAt != nil {
+		return errors.New("impersonation session has ended")
+	}
+	return nil
+}
+
+// jwtFailureReason maps a verification error to a low-cardinality metric label
+func jwtFailureReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return "bad_signature"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed"
+	default:
+		return "invalid"
+	}
+}
+
+// withUser stores the authenticated user, and tags the request logger with
+// the user's ID (never the email) so later log lines can be attributed
+func withUser(ctx context.Context, user *User) context.Context {
+	attrs := []any{"user_id", user.ID}
+	if user.APIKeyID != 0 {
+		attrs = append(attrs, "api_key_id", user.APIKeyID)
+	}
+	if user.Actor != nil {
+		attrs = append(attrs, "actor_id", user.Actor.Subject)
+	}
+
+	logger := logging.FromContext(ctx).With(attrs...)
+	logger.Debug("Request authenticated", "role", user.Role)
+	return logging.WithLogger(context.WithValue(ctx, UserCtxKey, user), logger)
 }
 
 // ForContext finds the user from the context. REQUIRES Middleware to have run.
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// API key scopes. A key without scopes acts with the full rights of its owner.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	// ScopeSCIM allows user provisioning through /scim/v2
+	ScopeSCIM = "scim"
+)
+
+// APIKey is a long-lived credential for scripts and CI jobs
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsActive reports whether the key may still be used
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
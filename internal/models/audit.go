@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditImpersonationStart = "impersonation.start"
+	AuditImpersonationStop  = "impersonation.stop"
+	AuditRoleChanged        = "user.role_changed"
+	AuditBootstrapAdmin     = "user.bootstrap_admin"
+	AuditUserImport         = "user.import"
+	AuditUserDeactivated    = "user.deactivated"
+	AuditUserReactivated    = "user.reactivated"
+	AuditUserDeleted        = "user.deleted"
+
+	AuditEmailChangeRequested = "user.email_change_requested"
+	AuditEmailChanged         = "user.email_changed"
+
+	AuditDataExported      = "user.data_exported"
+	AuditDeletionRequested = "user.deletion_requested"
+	AuditDeletionCancelled = "user.deletion_cancelled"
+	AuditUserAnonymized    = "user.anonymized"
+
+	AuditGroupCreated        = "group.created"
+	AuditGroupUpdated        = "group.updated"
+	AuditGroupDeleted        = "group.deleted"
+	AuditGroupRolesChanged   = "group.roles_changed"
+	AuditGroupMembersAdded   = "group.members_added"
+	AuditGroupMembersRemoved = "group.members_removed"
+	AuditSubgroupAdded       = "group.subgroup_added"
+	AuditSubgroupRemoved     = "group.subgroup_removed"
+
+	AuditJobRun = "job.run"
+)
+
+// AuditEntry records a security relevant action and who performed it
+type AuditEntry struct {
+	ID        int                    `json:"id"`
+	ActorID   *int                   `json:"actor_id"`
+	SubjectID *int                   `json:"subject_id"`
+	Action    string                 `json:"action"`
+	Reason    string                 `json:"reason"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// ImpersonationSession tracks a support user acting as another user
+type ImpersonationSession struct {
+	ID        int        `json:"id"`
+	ActorID   int        `json:"actor_id"`
+	SubjectID int        `json:"subject_id"`
+	Reason    string     `json:"reason"`
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+}
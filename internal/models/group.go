@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Group collects users, and other groups, that share roles. Members of a
+// group hold its roles in addition to their own, and so do the members of
+// its subgroups.
+type Group struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Roles       []string  `json:"roles"`
+	CreatedAt   time.Time `json:"created_at"`
+}
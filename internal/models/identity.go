@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Identity providers a user can sign in with
+const (
+	ProviderEmail  = "email"
+	ProviderGoogle = "google"
+)
+
+// Identity links an external login (provider + subject) to a user
+type Identity struct {
+	ID       int       `json:"id"`
+	UserID   int       `json:"user_id"`
+	Provider string    `json:"provider"`
+	Subject  string    `json:"subject"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linked_at"`
+}
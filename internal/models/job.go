@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Job run statuses
+const (
+	JobRunning   = "RUNNING"
+	JobSucceeded = "SUCCEEDED"
+	JobFailed    = "FAILED"
+)
+
+// JobRun records one run of a scheduled maintenance job
+type JobRun struct {
+	ID          int        `json:"id"`
+	Job         string     `json:"job"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	Runner      string     `json:"runner"`
+	Status      string     `json:"status"`
+	Affected    int64      `json:"affected"`
+	Error       string     `json:"error"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
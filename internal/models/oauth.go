@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// OAuthClient is an application registered to delegate login to this service
+type OAuthClient struct {
+	ID           int       `json:"id"`
+	ClientID     string    `json:"client_id"`
+	SecretHash   string    `json:"-"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// IsPublic reports whether the client authenticates with PKCE alone
+func (c *OAuthClient) IsPublic() bool {
+	return c.SecretHash == ""
+}
+
+// AuthorizationCode is a single-use grant issued by /authorize and redeemed at /token
+type AuthorizationCode struct {
+	ID            int       `json:"id"`
+	CodeHash      string    `json:"-"`
+	ClientID      string    `json:"client_id"`
+	UserID        int       `json:"user_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	Scope         string    `json:"scope"`
+	Nonce         string    `json:"nonce"`
+	CodeChallenge string    `json:"-"`
+	AuthTime      time.Time `json:"auth_time"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	IsUsed        bool      `json:"is_used"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// Permissions granted through roles
+const (
+	PermissionImpersonate = "users:impersonate"
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin:   {PermissionImpersonate},
+	RoleSupport: {PermissionImpersonate},
+}
+
+// RoleHasPermission reports whether a role grants a permission
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesHavePermission reports whether any of roles grants a permission
+func RolesHavePermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		if RoleHasPermission(role, permission) {
+			return true
+		}
+	}
+	return false
+}
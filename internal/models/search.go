@@ -0,0 +1,21 @@
+package models
+
+// UserSearchResult is a user matching a search, best matches scoring highest
+type UserSearchResult struct {
+	User       *User       `json:"user"`
+	Score      float64     `json:"score"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+// Highlight is a field of a search result and the parts of it that matched
+type Highlight struct {
+	Field   string       `json:"field"`
+	Value   string       `json:"value"`
+	Matches []MatchRange `json:"matches"`
+}
+
+// MatchRange is the part of a value from Start up to End, counted in characters
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
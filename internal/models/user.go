@@ -1,13 +1,30 @@
 package models
 
+import "time"
+
 const (
-	RoleAdmin = "ADMIN"
-	RoleUser  = "USER"
+	RoleAdmin   = "ADMIN"
+	RoleSupport = "SUPPORT"
+	RoleUser    = "USER"
 )
 
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleSupport, RoleUser:
+		return true
+	}
+	return false
+}
+
 type User struct {
 	ID    int    `json:"id"`
 	Name  string `json:"name"`
 	Email string `json:"email"`
 	Role  string `json:"role"`
+
+	// Version increases with every change to the user, for optimistic
+	// concurrency control
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
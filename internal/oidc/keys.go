@@ -0,0 +1,82 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+)
+
+// SigningKey is the RSA key ID tokens are signed with
+type SigningKey struct {
+	ID      string
+	Private *rsa.PrivateKey
+}
+
+// LoadSigningKey reads a PEM encoded RSA private key (PKCS#1 or PKCS#8).
+// With an empty path an ephemeral key is generated, which invalidates
+// previously issued ID tokens on every restart.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	if path == "" {
+		slog.Warn("OIDC_SIGNING_KEY_FILE not set: generating an ephemeral signing key")
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %v", err)
+		}
+		return newSigningKey(private), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	if private, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return newSigningKey(private), nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %v", err)
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key must be an RSA key")
+	}
+	return newSigningKey(private), nil
+}
+
+func newSigningKey(private *rsa.PrivateKey) *SigningKey {
+	sum := sha256.Sum256(private.PublicKey.N.Bytes())
+	return &SigningKey{
+		ID:      base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Private: private,
+	}
+}
+
+// JWKS returns the public half of the key as a JSON Web Key Set
+func (k *SigningKey) JWKS() map[string]interface{} {
+	public := k.Private.PublicKey
+	return map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": k.ID,
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	}
+}
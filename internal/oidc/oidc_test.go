@@ -0,0 +1,289 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+// connectTestDB connects to TEST_DATABASE_URL and applies the migrations,
+// skipping the test when no database is configured.
+func connectTestDB(t *testing.T) {
+	t.Helper()
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	if err := database.ConnectDB(context.Background(), databaseURL, database.DefaultPoolSettings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.DB.Exec(context.Background(), string(sql)); err != nil {
+			t.Fatalf("migration %s: %v", file, err)
+		}
+	}
+}
+
+// relyingParty is a minimal OIDC client, as an internal app would implement it
+type relyingParty struct {
+	t        *testing.T
+	issuer   string
+	clientID string
+	secret   string
+	verifier string
+	state    string
+	nonce    string
+	redirect string
+}
+
+func (rp *relyingParty) authorizeURL() string {
+	params := url.Values{
+		"client_id":             {rp.clientID},
+		"redirect_uri":          {rp.redirect},
+		"response_type":         {"code"},
+		"scope":                 {"openid email profile"},
+		"state":                 {rp.state},
+		"nonce":                 {rp.nonce},
+		"code_challenge":        {s256(rp.verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	return rp.issuer + "/authorize?" + params.Encode()
+}
+
+// callback redeems the code and verifies the ID token, replying with the signed-in email
+func (rp *relyingParty) callback(w http.ResponseWriter, r *http.Request) {
+	t := rp.t
+	if got := r.URL.Query().Get("state"); got != rp.state {
+		t.Errorf("state = %q, want %q", got, rp.state)
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		t.Errorf("callback without code: %s", r.URL.RawQuery)
+		http.Error(w, "no code", http.StatusBadRequest)
+		return
+	}
+
+	var discovery struct {
+		TokenEndpoint    string `json:"token_endpoint"`
+		UserinfoEndpoint string `json:"userinfo_endpoint"`
+		JWKSURI          string `json:"jwks_uri"`
+	}
+	getJSON(t, rp.issuer+"/.well-known/openid-configuration", &discovery)
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {rp.redirect},
+		"code_verifier": {rp.verifier},
+	}
+	exchange := func() *http.Response {
+		req, _ := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(rp.clientID, rp.secret)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := exchange()
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("token endpoint status %d", resp.StatusCode)
+	}
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&tokens)
+
+	// Authorization codes are single use
+	if replay := exchange(); replay.StatusCode != http.StatusBadRequest {
+		t.Errorf("replayed code status = %d, want 400", replay.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	getJSON(t, discovery.JWKSURI, &jwks)
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokens.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		for _, key := range jwks.Keys {
+			if key.Kid == token.Header["kid"] {
+				n, _ := base64.RawURLEncoding.DecodeString(key.N)
+				e, _ := base64.RawURLEncoding.DecodeString(key.E)
+				return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown kid %v", token.Header["kid"])
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(rp.issuer), jwt.WithAudience(rp.clientID))
+	if err != nil {
+		t.Fatalf("id token: %v", err)
+	}
+	if claims["nonce"] != rp.nonce {
+		t.Errorf("nonce = %v, want %s", claims["nonce"], rp.nonce)
+	}
+
+	req, _ := http.NewRequest("GET", discovery.UserinfoEndpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	info, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer info.Body.Close()
+	var userinfo map[string]interface{}
+	json.NewDecoder(info.Body).Decode(&userinfo)
+	if userinfo["sub"] != claims["sub"] {
+		t.Errorf("userinfo sub = %v, id token sub = %v", userinfo["sub"], claims["sub"])
+	}
+	if userinfo["email"] != claims["email"] {
+		t.Errorf("userinfo email = %v, id token email = %v", userinfo["email"], claims["email"])
+	}
+
+	fmt.Fprint(w, claims["email"])
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func postForm(t *testing.T, endpoint string, form url.Values) (int, string) {
+	resp, err := http.PostForm(endpoint, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body strings.Builder
+	buf := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buf)
+		body.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	return resp.StatusCode, body.String()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	connectTestDB(t)
+
+	key, err := LoadSigningKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	idp := httptest.NewServer(r)
+	defer idp.Close()
+	NewProvider(idp.URL, key).RegisterRoutes(r)
+
+	rp := &relyingParty{t: t, issuer: idp.URL, verifier: "verifier-" + fmt.Sprint(time.Now().UnixNano()), state: "xyz", nonce: "n-0S6_WzA2Mj"}
+	rpServer := httptest.NewServer(http.HandlerFunc(rp.callback))
+	defer rpServer.Close()
+	rp.redirect = rpServer.URL + "/callback"
+
+	client, secret, err := RegisterClient(context.Background(), "Test RP", []string{rp.redirect}, false)
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	rp.clientID, rp.secret = client.ClientID, secret
+
+	// 1. Browser is sent to /authorize and shown the login form
+	resp, err := http.Get(rp.authorizeURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("authorize status %d", resp.StatusCode)
+	}
+
+	// 2. User requests an OTP, then submits it
+	address := fmt.Sprintf("oidc-%d@example.com", time.Now().UnixNano())
+	form, _ := url.ParseQuery(strings.SplitN(rp.authorizeURL(), "?", 2)[1])
+	form.Set("email", address)
+	form.Set("step", "send")
+	if status, _ := postForm(t, idp.URL+"/authorize", form); status != http.StatusOK {
+		t.Fatalf("send OTP status %d", status)
+	}
+
+	otp, err := repository.GetLatestOTP(context.Background(), address)
+	if err != nil || otp == nil {
+		t.Fatalf("OTP not stored: %v", err)
+	}
+
+	// 3. Verifying redirects to the relying party, which redeems the code
+	form.Set("step", "verify")
+	form.Set("otp", otp.OTP)
+	status, body := postForm(t, idp.URL+"/authorize", form)
+	if status != http.StatusOK {
+		t.Fatalf("callback status %d: %s", status, body)
+	}
+	if body != address {
+		t.Errorf("relying party signed in %q, want %q", body, address)
+	}
+}
+
+func TestAuthorizeRejectsUnregisteredRedirect(t *testing.T) {
+	connectTestDB(t)
+
+	client, _, err := RegisterClient(context.Background(), "Test RP", []string{"https://app.example.com/callback"}, true)
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+
+	p := NewProvider("http://idp.test", nil)
+	req := httptest.NewRequest("GET", "/authorize?"+url.Values{
+		"client_id":    {client.ClientID},
+		"redirect_uri": {"https://evil.example.com/callback"},
+	}.Encode(), nil)
+	w := httptest.NewRecorder()
+	p.Authorize(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 without redirecting", w.Code)
+	}
+}
@@ -0,0 +1,174 @@
+// Package oidc lets other applications delegate login to this service using
+// OpenID Connect (authorization code flow with PKCE). Users authenticate with
+// the same email OTP flow as the GraphQL API.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// Supported scopes
+const (
+	ScopeOpenID  = "openid"
+	ScopeEmail   = "email"
+	ScopeProfile = "profile"
+)
+
+// ConsentHook decides whether a user grants a client the requested scopes.
+// Returning an error denies the request with access_denied.
+type ConsentHook func(ctx context.Context, client *models.OAuthClient, user *models.User, scopes []string) error
+
+// Provider serves the OIDC endpoints
+type Provider struct {
+	Issuer string
+	Key    *SigningKey
+	// Consent is consulted after the user has authenticated. When nil,
+	// consent is implied, which suits first-party internal apps.
+	Consent ConsentHook
+}
+
+// NewProvider creates a provider issuing tokens as issuer
+func NewProvider(issuer string, key *SigningKey) *Provider {
+	return &Provider{
+		Issuer: strings.TrimSuffix(issuer, "/"),
+		Key:    key,
+	}
+}
+
+// RegisterRoutes mounts the OIDC endpoints on the router
+func (p *Provider) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/.well-known/openid-configuration", p.Discovery).Methods("GET")
+	r.HandleFunc("/.well-known/jwks.json", p.JWKS).Methods("GET")
+	r.HandleFunc("/authorize", p.Authorize).Methods("GET", "POST")
+	r.HandleFunc("/token", p.Token).Methods("POST")
+	r.HandleFunc("/userinfo", p.UserInfo).Methods("GET", "POST")
+}
+
+// Discovery serves the OpenID Provider metadata document
+func (p *Provider) Discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"userinfo_endpoint":                     p.Issuer + "/userinfo",
+		"jwks_uri":                              p.Issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      []string{ScopeOpenID, ScopeEmail, ScopeProfile},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "name"},
+	})
+}
+
+// JWKS serves the public signing key
+func (p *Provider) JWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, p.Key.JWKS())
+}
+
+// RegisterClient creates a relying party. Confidential clients receive a
+// secret which is only returned here; public clients rely on PKCE alone.
+func RegisterClient(ctx context.Context, name string, redirectURIs []string, public bool) (*models.OAuthClient, string, error) {
+	if name == "" {
+		return nil, "", errors.New("client name is required")
+	}
+	if len(redirectURIs) == 0 {
+		return nil, "", errors.New("at least one redirect URI is required")
+	}
+	for _, uri := range redirectURIs {
+		if !strings.HasPrefix(uri, "https://") && !strings.HasPrefix(uri, "http://localhost") && !strings.HasPrefix(uri, "http://127.0.0.1") {
+			return nil, "", errors.New("redirect URIs must use https (or http on localhost)")
+		}
+	}
+
+	clientID, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := &models.OAuthClient{
+		ClientID:     clientID,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+	}
+
+	var secret string
+	if !public {
+		if secret, err = randomToken(32); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashToken(secret)
+	}
+
+	if err := repository.CreateOAuthClient(ctx, client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// authenticateClient checks a confidential client's secret
+func authenticateClient(client *models.OAuthClient, secret string) bool {
+	if client.IsPublic() {
+		return secret == ""
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) == 1
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// s256 computes the PKCE S256 code challenge for a verifier
+func s256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeOAuthError writes an RFC 6749 error response
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
@@ -0,0 +1,166 @@
+package oidc
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-management-service/internal/auth"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const idTokenTTL = 15 * time.Minute
+
+// Token exchanges an authorization code for an access token and ID token.
+// The access token is a regular service JWT, so it is also accepted by
+// /graphql; it also records the granted scopes for UserInfo.
+func (p *Provider) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostFormValue("client_id")
+		secret = r.PostFormValue("client_secret")
+	}
+
+	client, err := repository.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "failed to load client")
+		return
+	}
+	if client == nil || !authenticateClient(client, secret) {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	if grantType := r.PostFormValue("grant_type"); grantType != "authorization_code" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	grant, err := repository.ConsumeAuthorizationCode(r.Context(), hashToken(r.PostFormValue("code")))
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "failed to redeem code")
+		return
+	}
+	if grant == nil || grant.ClientID != client.ClientID || grant.RedirectURI != r.PostFormValue("redirect_uri") {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid, expired or already used")
+		return
+	}
+
+	if s256(r.PostFormValue("code_verifier")) != grant.CodeChallenge {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	user, err := repository.GetUserByID(r.Context(), grant.UserID)
+	if err != nil || user == nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "user no longer exists")
+		return
+	}
+
+	accessToken, err := auth.IssueAccessToken(r.Context(), user, grant.Scope)
+	if errors.Is(err, repository.ErrUserDeactivated) {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "account is deactivated")
+		return
+	}
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "failed to issue access token")
+		return
+	}
+
+	idToken, err := p.signIDToken(client, user, grant)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "failed to issue id token")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(auth.SessionTTL / time.Second),
+		"id_token":     idToken,
+		"scope":        grant.Scope,
+	})
+}
+
+func (p *Provider) signIDToken(client *models.OAuthClient, user *models.User, grant *models.AuthorizationCode) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       p.Issuer,
+		"sub":       strconv.Itoa(user.ID),
+		"aud":       client.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(idTokenTTL).Unix(),
+		"auth_time": grant.AuthTime.Unix(),
+	}
+	if grant.Nonce != "" {
+		claims["nonce"] = grant.Nonce
+	}
+	for key, value := range userClaims(user, strings.Fields(grant.Scope)) {
+		claims[key] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.Key.ID
+	return token.SignedString(p.Key.Private)
+}
+
+// UserInfo returns the claims for the user behind an access token, limited
+// to the scopes granted to the client. The token goes through the same
+// session checks as any other request, so revoked sessions are refused.
+// Only access tokens issued by Token are accepted; session tokens, API keys
+// and impersonation tokens are refused.
+func (p *Provider) UserInfo(w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "a bearer access token is required")
+		return
+	}
+
+	ctx, err := middleware.AuthenticateAccessToken(r.Context(), header)
+	caller := middleware.ForContext(ctx)
+	if err != nil || caller == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "access token is invalid, expired or revoked")
+		return
+	}
+
+	id, err := strconv.Atoi(caller.ID)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "access token has no user")
+		return
+	}
+
+	user, err := repository.GetUserByID(r.Context(), id)
+	if err != nil || user == nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "user no longer exists")
+		return
+	}
+
+	info := userClaims(user, caller.OAuthScopes)
+	info["sub"] = strconv.Itoa(user.ID)
+	writeJSON(w, http.StatusOK, info)
+}
+
+// userClaims returns the standard claims released for the granted scopes
+func userClaims(user *models.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	if hasScope(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+	}
+	if hasScope(scopes, ScopeProfile) {
+		claims["name"] = user.Name
+	}
+	return claims
+}
@@ -0,0 +1,59 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	emailpkg "user-management-service/internal/email"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// RequestDeletion schedules user's account to be anonymized once grace has
+// passed, tells them by email and returns when it will happen. Until then
+// the user can cancel with CancelDeletion.
+func RequestDeletion(ctx context.Context, user *models.User, grace time.Duration) (time.Time, error) {
+	at := time.Now().Add(grace)
+	if err := repository.ScheduleAccountDeletion(ctx, user.ID, at); err != nil {
+		return time.Time{}, err
+	}
+	if err := emailpkg.SendAccountDeletionNotice(ctx, user.Email, at); err != nil {
+		logging.FromContext(ctx).Error("Failed to send account deletion notice", "user_id", user.ID, "err", err)
+	}
+	return at, nil
+}
+
+// CancelDeletion withdraws a scheduled deletion of the user and reports
+// whether one was pending
+func CancelDeletion(ctx context.Context, userID int) (bool, error) {
+	return repository.CancelAccountDeletion(ctx, userID)
+}
+
+// EraseDue anonymizes the accounts whose grace period has ended and
+// returns how many were erased. An account that fails is left for the next
+// run and the others are still erased; the failures are returned together.
+func EraseDue(ctx context.Context) (int, error) {
+	ids, err := repository.GetDueAccountDeletions(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due deletions: %v", err)
+	}
+
+	erased := 0
+	var errs []error
+	for _, id := range ids {
+		if err := repository.AnonymizeUser(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %v", id, err))
+			continue
+		}
+		erased++
+
+		entry := &models.AuditEntry{SubjectID: &id, Action: models.AuditUserAnonymized}
+		if err := repository.RecordAudit(ctx, entry); err != nil {
+			logging.FromContext(ctx).Error("Failed to record audit entry", "action", entry.Action, "err", err)
+		}
+	}
+	return erased, errors.Join(errs...)
+}
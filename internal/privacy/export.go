@@ -0,0 +1,94 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+)
+
+// ExportContentType is the media type of a data export
+const ExportContentType = "application/zip"
+
+// ExportFilename names the data export of a user
+func ExportFilename(user *models.User) string {
+	return fmt.Sprintf("user-%d-data.zip", user.ID)
+}
+
+// otpRecord is an OTP as exported; the code itself is left out
+type otpRecord struct {
+	ID           int       `json:"id"`
+	Email        string    `json:"email"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	IsUsed       bool      `json:"is_used"`
+	AttemptCount int       `json:"attempt_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WriteExport writes a ZIP archive of everything stored about user to w:
+// their profile, identities, sessions, API keys, OTP history and the audit
+// entries they are the actor or subject of, one JSON file each
+func WriteExport(ctx context.Context, w io.Writer, user *models.User) error {
+	identities, err := repository.GetIdentitiesByUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %v", err)
+	}
+	sessions, err := repository.GetSessionsByUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load sessions: %v", err)
+	}
+	apiKeys, err := repository.GetAPIKeysByUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load API keys: %v", err)
+	}
+	otps, err := repository.GetOTPsByEmail(ctx, user.Email)
+	if err != nil {
+		return fmt.Errorf("failed to load OTP history: %v", err)
+	}
+	audit, err := repository.GetAuditLog(ctx, &user.ID, math.MaxInt32)
+	if err != nil {
+		return fmt.Errorf("failed to load audit log: %v", err)
+	}
+
+	otpHistory := make([]otpRecord, len(otps))
+	for i, otp := range otps {
+		otpHistory[i] = otpRecord{
+			ID:           otp.ID,
+			Email:        otp.Email,
+			ExpiresAt:    otp.ExpiresAt,
+			IsUsed:       otp.IsUsed,
+			AttemptCount: otp.AttemptCount,
+			CreatedAt:    otp.CreatedAt,
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", user},
+		{"identities.json", identities},
+		{"sessions.json", sessions},
+		{"api_keys.json", apiKeys},
+		{"otps.json", otpHistory},
+		{"audit_log.json", audit},
+	} {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return fmt.Errorf("failed to write %s: %v", file.name, err)
+		}
+	}
+	return zw.Close()
+}
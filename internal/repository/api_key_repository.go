@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrAPIKeyNotFound is returned when an API key does not exist or belongs to another user
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row pgx.Row, key *models.APIKey) error {
+	return row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.Scopes,
+		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+}
+
+// CreateAPIKey stores a new (already hashed) API key
+func CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	err := database.DB.QueryRow(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error creating API key", "err", err)
+		return err
+	}
+	return nil
+}
+
+// GetAPIKeyByPrefix fetches an API key by its public prefix, or nil if
+// unknown. Keys of deactivated users are treated as unknown.
+func GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys
+			  WHERE prefix = $1 AND EXISTS (SELECT 1 FROM users WHERE users.id = api_keys.user_id AND users.active)`
+
+	var key models.APIKey
+	if err := scanAPIKey(database.DB.QueryRow(ctx, query, prefix), &key); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logging.FromContext(ctx).Error("Error fetching API key", "err", err)
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeysByUser lists a user's API keys, including revoked ones
+func GetAPIKeysByUser(ctx context.Context, userID int) ([]*models.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := database.DB.Query(ctx, query, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error querying API keys", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			logging.FromContext(ctx).Error("Error scanning API key row", "err", err)
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes one of a user's API keys
+func RevokeAPIKey(ctx context.Context, userID, id int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := database.DB.Exec(ctx, query, id, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error revoking API key", "err", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey records that a key was used. Writes are throttled to once a
+// minute per key so busy scripts do not turn every request into an UPDATE.
+func TouchAPIKey(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	query := `UPDATE api_keys SET last_used_at = NOW()
+			  WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+	_, err := database.DB.Exec(ctx, query, id)
+	return err
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordAudit appends an entry to the audit log
+func RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	return insertAudit(ctx, database.DB, entry)
+}
+
+// insertAudit appends entry to the audit log through db, which may be the
+// transaction making the audited change
+func insertAudit(ctx context.Context, db queryRower, entry *models.AuditEntry) error {
+	if entry.Metadata == nil {
+		entry.Metadata = map[string]interface{}{}
+	}
+
+	query := `INSERT INTO audit_log (actor_user_id, subject_user_id, action, reason, metadata)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	err := db.QueryRow(ctx, query, entry.ActorID, entry.SubjectID, entry.Action, entry.Reason, entry.Metadata).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error recording audit entry", "err", err)
+		return err
+	}
+	return nil
+}
+
+// GetAuditLog returns the most recent audit entries, optionally for one subject user
+func GetAuditLog(ctx context.Context, subjectID *int, limit int) ([]*models.AuditEntry, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id, actor_user_id, subject_user_id, action, reason, metadata, created_at FROM audit_log
+			  WHERE $1::INTEGER IS NULL OR subject_user_id = $1 OR actor_user_id = $1
+			  ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := database.DB.Query(ctx, query, subjectID, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error querying audit log", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.SubjectID, &entry.Action, &entry.Reason, &entry.Metadata, &entry.CreatedAt); err != nil {
+			logging.FromContext(ctx).Error("Error scanning audit row", "err", err)
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// StartImpersonation opens an impersonation session and records it in the audit log
+func StartImpersonation(ctx context.Context, session *models.ImpersonationSession) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO impersonation_sessions (actor_user_id, subject_user_id, reason, expires_at)
+			  VALUES ($1, $2, $3, $4) RETURNING id, started_at`
+	err = tx.QueryRow(ctx, query, session.ActorID, session.SubjectID, session.Reason, session.ExpiresAt).
+		Scan(&session.ID, &session.StartedAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error starting impersonation", "err", err)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO audit_log (actor_user_id, subject_user_id, action, reason, metadata)
+						   VALUES ($1, $2, $3, $4, $5)`,
+		session.ActorID, session.SubjectID, models.AuditImpersonationStart, session.Reason,
+		map[string]interface{}{"session_id": session.ID, "expires_at": session.ExpiresAt})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error auditing impersonation", "err", err)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetImpersonationSession fetches an impersonation session, or nil if unknown
+func GetImpersonationSession(ctx context.Context, id int) (*models.ImpersonationSession, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id, actor_user_id, subject_user_id, reason, started_at, expires_at, ended_at
+			  FROM impersonation_sessions WHERE id = $1`
+
+	var session models.ImpersonationSession
+	err := database.DB.QueryRow(ctx, query, id).Scan(
+		&session.ID, &session.ActorID, &session.SubjectID, &session.Reason,
+		&session.StartedAt, &session.ExpiresAt, &session.EndedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logging.FromContext(ctx).Error("Error fetching impersonation session", "err", err)
+		return nil, err
+	}
+	return &session, nil
+}
+
+// StopImpersonation ends an open impersonation session and records it in the audit log
+func StopImpersonation(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var actorID, subjectID int
+	query := `UPDATE impersonation_sessions SET ended_at = NOW()
+			  WHERE id = $1 AND ended_at IS NULL RETURNING actor_user_id, subject_user_id`
+	if err := tx.QueryRow(ctx, query, id).Scan(&actorID, &subjectID); err != nil {
+		if err == pgx.ErrNoRows {
+			return errors.New("impersonation session not found or already ended")
+		}
+		logging.FromContext(ctx).Error("Error stopping impersonation", "err", err)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO audit_log (actor_user_id, subject_user_id, action, metadata)
+						   VALUES ($1, $2, $3, $4)`,
+		actorID, subjectID, models.AuditImpersonationStop, map[string]interface{}{"session_id": id})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error auditing impersonation", "err", err)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ImportResult counts what ImportUsers did with a batch. Existing lists the
+// emails that were left alone because they already belong to a user, in
+// insert mode.
+type ImportResult struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Existing  []string
+}
+
+// ImportUsers writes a batch of users in one transaction, streaming them to
+// the server with COPY. In upsert mode users that already exist, matched by
+// email, get the batch's name and, when it is set, role; otherwise they are
+// left alone and reported in Existing. An empty Role means USER for new
+// users and no change for existing ones. Role changes are guarded and
+// audited as SetUserRole's are: a batch that would leave no administrator
+// fails with ErrLastAdmin, and each change is recorded with actorID.
+func ImportUsers(ctx context.Context, users []*models.User, upsert bool, actorID *int) (*ImportResult, error) {
+	ctx, cancel := withTimeout(ctx, opBulk)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE users_import (email TEXT PRIMARY KEY, name TEXT NOT NULL, role TEXT) ON COMMIT DROP`); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]interface{}, len(users))
+	for i, u := range users {
+		var role interface{}
+		if u.Role != "" {
+			role = u.Role
+		}
+		rows[i] = []interface{}{u.Email, u.Name, role}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"users_import"}, []string{"email", "name", "role"}, pgx.CopyFromRows(rows)); err != nil {
+		logging.FromContext(ctx).Error("Error copying import batch", "err", err)
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	if upsert {
+		if err := upsertImportedUsers(ctx, tx, result, actorID); err != nil {
+			return nil, err
+		}
+	}
+
+	newRows, err := tx.Query(ctx, `INSERT INTO users (name, email, role)
+		SELECT name, email, COALESCE(role, 'USER') FROM users_import
+		ON CONFLICT (email) DO NOTHING
+		RETURNING id, email, role`)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error inserting imported users", "err", err)
+		return nil, err
+	}
+	type insertedUser struct {
+		id          int
+		email, role string
+	}
+	var inserted []insertedUser
+	for newRows.Next() {
+		var u insertedUser
+		if err := newRows.Scan(&u.id, &u.email, &u.role); err != nil {
+			newRows.Close()
+			return nil, err
+		}
+		inserted = append(inserted, u)
+	}
+	if err := newRows.Err(); err != nil {
+		logging.FromContext(ctx).Error("Error inserting imported users", "err", err)
+		return nil, err
+	}
+	result.Inserted = len(inserted)
+
+	// New users created with a privileged role are audited like promotions
+	for _, u := range inserted {
+		if u.role == models.RoleUser {
+			continue
+		}
+		err := insertAudit(ctx, tx, &models.AuditEntry{
+			ActorID:   actorID,
+			SubjectID: &u.id,
+			Action:    models.AuditRoleChanged,
+			Metadata:  map[string]interface{}{"to": u.role, "source": "import"},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	isNew := make(map[string]bool, len(inserted))
+	for _, u := range inserted {
+		isNew[u.email] = true
+	}
+	for _, u := range users {
+		if !isNew[u.Email] && !upsert {
+			result.Existing = append(result.Existing, u.Email)
+		}
+	}
+	if upsert {
+		result.Unchanged = len(users) - result.Inserted - result.Updated
+	}
+	return result, nil
+}
+
+// upsertImportedUsers updates the existing users of an import batch,
+// checking and auditing their role changes
+func upsertImportedUsers(ctx context.Context, tx pgx.Tx, result *ImportResult, actorID *int) error {
+	// Two concurrent demotions must not both succeed, see SetUserRole
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, adminsLock); err != nil {
+		return err
+	}
+
+	// Only rewrite rows that change, so unchanged users are not touched
+	rows, err := tx.Query(ctx, `UPDATE users u SET name = i.name, role = COALESCE(i.role, old.role)
+		FROM users_import i
+		JOIN (SELECT id, email, name, role FROM users WHERE email IN (SELECT email FROM users_import) FOR UPDATE) old
+			ON old.email = i.email
+		WHERE u.id = old.id AND (old.name, old.role) IS DISTINCT FROM (i.name, COALESCE(i.role, old.role))
+		RETURNING u.id, old.role, u.role`)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error updating imported users", "err", err)
+		return err
+	}
+
+	type roleChange struct {
+		id       int
+		from, to string
+	}
+	var changes []roleChange
+	for rows.Next() {
+		var c roleChange
+		if err := rows.Scan(&c.id, &c.from, &c.to); err != nil {
+			rows.Close()
+			return err
+		}
+		result.Updated++
+		if c.from != c.to {
+			changes = append(changes, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("Error updating imported users", "err", err)
+		return err
+	}
+
+	demoted := false
+	for _, c := range changes {
+		demoted = demoted || (c.from == models.RoleAdmin && c.to != models.RoleAdmin)
+	}
+	if demoted {
+		exists, err := adminExists(ctx, tx, 0)
+		if err != nil {
+			logging.FromContext(ctx).Error("Error counting administrators", "err", err)
+			return err
+		}
+		if !exists {
+			return ErrLastAdmin
+		}
+	}
+
+	for _, c := range changes {
+		err := insertAudit(ctx, tx, &models.AuditEntry{
+			ActorID:   actorID,
+			SubjectID: &c.id,
+			Action:    models.AuditRoleChanged,
+			Metadata:  map[string]interface{}{"from": c.from, "to": c.to, "source": "import"},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserFilter narrows StreamUsers and ListDirectoryUsers. Empty fields do
+// not filter.
+type UserFilter struct {
+	Role        string
+	EmailDomain string
+	// Search matches a substring of the name or email, case-insensitively
+	Search string
+	Email  string
+	Active *bool
+}
+
+// where builds the WHERE clause for the filter, or "" for no filter
+func (f UserFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", "$"+strconv.Itoa(len(args))))
+	}
+	if f.Role != "" {
+		add(`role = $?`, f.Role)
+	}
+	if f.EmailDomain != "" {
+		add(`lower(split_part(email, '@', 2)) = lower($?)`, f.EmailDomain)
+	}
+	if f.Search != "" {
+		add(`(name ILIKE $? OR email ILIKE $?)`, "%"+escapeLike(f.Search)+"%")
+	}
+	if f.Email != "" {
+		add(`email = $?`, f.Email)
+	}
+	if f.Active != nil {
+		add(`active = $?`, *f.Active)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// StreamUsers calls fn for every user matching filter, in ID order, without
+// loading them all into memory. It stops at the first error fn returns.
+func StreamUsers(ctx context.Context, filter UserFilter, fn func(*models.User) error) error {
+	ctx, cancel := withTimeout(ctx, opBulk)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	where, args := filter.where()
+	query := `SELECT id, name, email, role FROM users` + where + ` ORDER BY id`
+
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error exporting users", "err", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Role); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// escapeLike makes s match literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Errors returned when provisioning users
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("a user with this email already exists")
+)
+
+// DirectoryUser is a user as provisioning clients see it, including whether
+// the account is active
+type DirectoryUser struct {
+	models.User
+	Active bool
+}
+
+// Provisioning clients read their own writes straight back, so these
+// functions use the primary rather than a possibly lagging replica.
+
+// ListDirectoryUsers returns a page of the users matching filter in ID
+// order, and how many match in total. A limit of 0 only counts.
+func ListDirectoryUsers(ctx context.Context, filter UserFilter, offset, limit int) ([]*DirectoryUser, int, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, 0, errors.New("database connection is not initialized")
+	}
+
+	where, args := filter.where()
+
+	var total int
+	if err := database.DB.QueryRow(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		logging.FromContext(ctx).Error("Error counting users", "err", err)
+		return nil, 0, err
+	}
+	users := []*DirectoryUser{}
+	if limit == 0 || offset >= total {
+		return users, total, nil
+	}
+
+	n := len(args)
+	query := `SELECT id, name, email, role, active FROM users` + where +
+		` ORDER BY id OFFSET $` + strconv.Itoa(n+1) + ` LIMIT $` + strconv.Itoa(n+2)
+	rows, err := database.DB.Query(ctx, query, append(args, offset, limit)...)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error listing users", "err", err)
+		return nil, 0, err
+	}
+	users, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*DirectoryUser, error) {
+		var user DirectoryUser
+		err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Active)
+		return &user, err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error listing users", "err", err)
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// GetDirectoryUser fetches a user by ID, or nil if there is none
+func GetDirectoryUser(ctx context.Context, id int) (*DirectoryUser, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id, name, email, role, active FROM users WHERE id = $1`
+
+	var user DirectoryUser
+	err := database.DB.QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Active)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logging.FromContext(ctx).Error("Error fetching user", "err", err)
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateDirectoryUser inserts a user, failing with ErrEmailTaken if the
+// email is in use
+func CreateDirectoryUser(ctx context.Context, user *DirectoryUser) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	query := `INSERT INTO users (name, email, role, active) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	err := database.DB.QueryRow(ctx, query, user.Name, user.Email, user.Role, user.Active).Scan(&user.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		logging.FromContext(ctx).Error("Error creating user", "err", err)
+		return err
+	}
+	return nil
+}
+
+// ErrLastAdminDeactivation is returned by UpdateDirectoryUser when
+// deactivating the user would leave no administrator
+var ErrLastAdminDeactivation = errors.New("the last administrator cannot be deactivated")
+
+// UpdateDirectoryUser saves a user's name, email and active flag. A new
+// email moves the user's email sign-in identity and revokes their
+// sessions. Deactivating a user also revokes their sessions and ends
+// impersonation of them, so they are signed out everywhere at once; it
+// fails with ErrLastAdminDeactivation for the last administrator.
+func UpdateDirectoryUser(ctx context.Context, user *DirectoryUser) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if !user.Active {
+		last, err := isLastAdmin(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if last {
+			return ErrLastAdminDeactivation
+		}
+	}
+
+	query := `UPDATE users u SET name = $1, email = $2, active = $3
+			  FROM (SELECT id, email FROM users WHERE id = $4 FOR UPDATE) old
+			  WHERE u.id = old.id
+			  RETURNING u.role, old.email`
+
+	var previous string
+	err = tx.QueryRow(ctx, query, user.Name, user.Email, user.Active, user.ID).Scan(&user.Role, &previous)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrUserNotFound
+		}
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		logging.FromContext(ctx).Error("Error updating user", "err", err)
+		return err
+	}
+
+	if previous != user.Email {
+		if err := moveEmail(ctx, tx, user.ID, previous, user.Email); err != nil {
+			return err
+		}
+	}
+
+	if !user.Active {
+		if _, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, user.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE impersonation_sessions SET ended_at = NOW() WHERE subject_user_id = $1 AND ended_at IS NULL`, user.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
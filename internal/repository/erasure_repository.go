@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AnonymizedName replaces the name of an erased user
+const AnonymizedName = "Deleted user"
+
+// ErrLastAdminDeletion is returned when erasing a user would leave no
+// administrator
+var ErrLastAdminDeletion = errors.New("the last administrator cannot delete their account")
+
+// ScheduleAccountDeletion marks a user to be anonymized at the given time.
+// The last administrator cannot schedule their own deletion.
+func ScheduleAccountDeletion(ctx context.Context, id int, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := requireOtherAdmin(ctx, tx, id); err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET deletion_scheduled_at = $1 WHERE id = $2 AND anonymized_at IS NULL`
+
+	result, err := tx.Exec(ctx, query, at, id)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error scheduling account deletion", "err", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+// CancelAccountDeletion withdraws a scheduled deletion and reports whether
+// one was pending
+func CancelAccountDeletion(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return false, errors.New("database connection is not initialized")
+	}
+
+	query := `UPDATE users SET deletion_scheduled_at = NULL
+			  WHERE id = $1 AND deletion_scheduled_at IS NOT NULL AND anonymized_at IS NULL`
+
+	result, err := database.DB.Exec(ctx, query, id)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error cancelling account deletion", "err", err)
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// GetDueAccountDeletions lists up to 100 users whose grace period ended
+// before now
+func GetDueAccountDeletions(ctx context.Context, now time.Time) ([]int, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id FROM users WHERE deletion_scheduled_at <= $1 AND anonymized_at IS NULL
+			  ORDER BY deletion_scheduled_at LIMIT 100`
+
+	rows, err := database.DB.Query(ctx, query, now)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error listing due account deletions", "err", err)
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
+
+// AnonymizeUser erases a user's personal data. Their name and email are
+// replaced, identities, group memberships, OTPs and authorization codes are
+// deleted, sessions and API keys revoked and impersonations of them ended.
+// A role other than USER is taken away and the change audited. The row itself stays, so audit
+// entries keep pointing at it, but the addresses recorded by their email
+// changes are removed from those entries. OTPs are deleted for every
+// address the user is known to have held, not just the current one.
+func AnonymizeUser(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := requireOtherAdmin(ctx, tx, id); err != nil {
+		return err
+	}
+
+	query := `UPDATE users u SET name = $1, email = 'deleted-' || u.id || '@deleted.invalid', role = $2, active = FALSE,
+				  pending_email = NULL, email_change_token_hash = NULL, email_change_expires_at = NULL,
+				  deletion_scheduled_at = NULL, anonymized_at = NOW()
+			  FROM (SELECT id, email, pending_email, role FROM users WHERE id = $3 AND anonymized_at IS NULL FOR UPDATE) old
+			  WHERE u.id = old.id RETURNING old.email, old.pending_email, old.role`
+
+	var email, role string
+	var pendingEmail *string
+	if err := tx.QueryRow(ctx, query, AnonymizedName, models.RoleUser, id).Scan(&email, &pendingEmail, &role); err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrUserNotFound
+		}
+		logging.FromContext(ctx).Error("Error anonymizing user", "err", err)
+		return err
+	}
+
+	emailChanges := []string{models.AuditEmailChangeRequested, models.AuditEmailChanged}
+
+	// Runs first: the addresses are collected from the identities and audit
+	// entries the later statements remove
+	otps := `DELETE FROM otps WHERE LOWER(email) IN (
+				 SELECT LOWER(a) FROM UNNEST($2::TEXT[]) a
+				 UNION SELECT LOWER(email) FROM user_identities WHERE user_id = $1
+				 UNION SELECT LOWER(metadata->>'from') FROM audit_log WHERE subject_user_id = $1 AND action = ANY($3)
+				 UNION SELECT LOWER(metadata->>'to') FROM audit_log WHERE subject_user_id = $1 AND action = ANY($3)
+			 )`
+	addresses := []string{email}
+	if pendingEmail != nil {
+		addresses = append(addresses, *pendingEmail)
+	}
+
+	for _, stmt := range []struct {
+		query string
+		args  []any
+	}{
+		{otps, []any{id, addresses, emailChanges}},
+		{`UPDATE audit_log SET metadata = metadata - 'from' - 'to' WHERE subject_user_id = $1 AND action = ANY($2)`, []any{id, emailChanges}},
+		{`DELETE FROM user_identities WHERE user_id = $1`, []any{id}},
+		{`DELETE FROM group_members WHERE user_id = $1`, []any{id}},
+		{`DELETE FROM oauth_authorization_codes WHERE user_id = $1`, []any{id}},
+		{`UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, []any{id}},
+		{`UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, []any{id}},
+		{`UPDATE impersonation_sessions SET ended_at = NOW() WHERE subject_user_id = $1 AND ended_at IS NULL`, []any{id}},
+	} {
+		if _, err := tx.Exec(ctx, stmt.query, stmt.args...); err != nil {
+			logging.FromContext(ctx).Error("Error erasing user data", "err", err)
+			return err
+		}
+	}
+
+	if role != models.RoleUser {
+		err := insertAudit(ctx, tx, &models.AuditEntry{
+			SubjectID: &id,
+			Action:    models.AuditRoleChanged,
+			Metadata:  map[string]interface{}{"from": role, "to": models.RoleUser, "source": "erasure"},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// requireOtherAdmin fails with ErrLastAdminDeletion if id is an
+// administrator and no other active one exists
+func requireOtherAdmin(ctx context.Context, tx pgx.Tx, id int) error {
+	last, err := isLastAdmin(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if last {
+		return ErrLastAdminDeletion
+	}
+	return nil
+}
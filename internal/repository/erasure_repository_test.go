@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/models"
+)
+
+func TestAnonymizeUser(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	if err := database.ConnectDB(context.Background(), databaseURL, database.DefaultPoolSettings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+
+	ctx := context.Background()
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	suffix := time.Now().UnixNano()
+	email := fmt.Sprintf("erase-%d@example.com", suffix)
+	user := &models.User{Name: "Leaver", Email: email, Role: models.RoleSupport}
+	identity := &models.Identity{Provider: models.ProviderEmail, Subject: strings.ToLower(email), Email: email}
+	if err := CreateUserWithIdentity(ctx, user, identity); err != nil {
+		t.Fatalf("CreateUserWithIdentity: %v", err)
+	}
+	t.Cleanup(func() { DeleteUser(context.Background(), user.ID, nil, "test") })
+
+	session := &models.Session{ID: fmt.Sprintf("erase-%d", suffix), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	entry := &models.AuditEntry{ActorID: &user.ID, SubjectID: &user.ID, Action: models.AuditDeletionRequested}
+	if err := RecordAudit(ctx, entry); err != nil {
+		t.Fatalf("RecordAudit: %v", err)
+	}
+
+	// An address the user held before, still known from an email change
+	oldEmail := fmt.Sprintf("erase-old-%d@example.com", suffix)
+	change := &models.AuditEntry{
+		ActorID:   &user.ID,
+		SubjectID: &user.ID,
+		Action:    models.AuditEmailChanged,
+		Metadata:  map[string]interface{}{"from": oldEmail, "to": email},
+	}
+	if err := RecordAudit(ctx, change); err != nil {
+		t.Fatalf("RecordAudit: %v", err)
+	}
+	if err := SaveOTP(ctx, &models.OTP{Email: oldEmail, OTP: "123456", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveOTP: %v", err)
+	}
+
+	actor := &models.User{Name: "Support", Email: fmt.Sprintf("erase-actor-%d@example.com", suffix), Role: models.RoleSupport}
+	if err := CreateUser(ctx, actor); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	t.Cleanup(func() { DeleteUser(context.Background(), actor.ID, nil, "test") })
+	impersonation := &models.ImpersonationSession{ActorID: actor.ID, SubjectID: user.ID, Reason: "ticket", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := StartImpersonation(ctx, impersonation); err != nil {
+		t.Fatalf("StartImpersonation: %v", err)
+	}
+
+	if err := ScheduleAccountDeletion(ctx, user.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("ScheduleAccountDeletion: %v", err)
+	}
+	due, err := GetDueAccountDeletions(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("GetDueAccountDeletions: %v", err)
+	}
+	found := false
+	for _, id := range due {
+		found = found || id == user.ID
+	}
+	if !found {
+		t.Fatalf("expected user %d to be due for deletion, got %v", user.ID, due)
+	}
+
+	if err := AnonymizeUser(ctx, user.ID); err != nil {
+		t.Fatalf("AnonymizeUser: %v", err)
+	}
+	if err := AnonymizeUser(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected a second erasure to find nothing, got %v", err)
+	}
+
+	erased, err := GetUserByID(ctx, user.ID)
+	if err != nil || erased == nil {
+		t.Fatalf("expected the user row to be kept, got %+v (%v)", erased, err)
+	}
+	if erased.Name != AnonymizedName || strings.Contains(erased.Email, "erase-") {
+		t.Errorf("expected the name and email to be replaced, got %q <%s>", erased.Name, erased.Email)
+	}
+	if identities, err := GetIdentitiesByUser(ctx, user.ID); err != nil || len(identities) != 0 {
+		t.Errorf("expected identities to be removed, got %v (%v)", identities, err)
+	}
+	if got, err := GetSession(ctx, session.ID); err != nil || got == nil || got.RevokedAt == nil {
+		t.Errorf("expected the session to be revoked, got %+v (%v)", got, err)
+	}
+	if got, err := GetImpersonationSession(ctx, impersonation.ID); err != nil || got == nil || got.EndedAt == nil {
+		t.Errorf("expected the impersonation to be ended, got %+v (%v)", got, err)
+	}
+	if erased.Role != models.RoleUser {
+		t.Errorf("expected the role to be reset to USER, got %s", erased.Role)
+	}
+
+	entries, err := GetAuditLog(ctx, &user.ID, 10)
+	if err != nil {
+		t.Fatalf("GetAuditLog: %v", err)
+	}
+	if len(entries) == 0 || *entries[len(entries)-1].SubjectID != user.ID {
+		t.Errorf("expected audit entries to still reference the user, got %v", entries)
+	}
+	roleAudited := false
+	for _, e := range entries {
+		roleAudited = roleAudited || (e.Action == models.AuditRoleChanged && e.Metadata["from"] == models.RoleSupport && e.Metadata["to"] == models.RoleUser)
+		if e.Action == models.AuditEmailChanged && (e.Metadata["from"] != nil || e.Metadata["to"] != nil) {
+			t.Errorf("expected email addresses to be scrubbed from the audit log, got %v", e.Metadata)
+		}
+	}
+	if !roleAudited {
+		t.Errorf("expected the role change to USER to be audited, got %v", entries)
+	}
+	if otps, err := GetOTPsByEmail(ctx, oldEmail); err != nil || len(otps) != 0 {
+		t.Errorf("expected OTPs for a previous address to be deleted, got %v (%v)", otps, err)
+	}
+}
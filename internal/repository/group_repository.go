@@ -0,0 +1,476 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Errors returned when managing groups
+var (
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrGroupNameTaken = errors.New("a group with this name already exists")
+	ErrGroupCycle     = errors.New("a group cannot be nested inside itself or its subgroups")
+)
+
+// groupColumns selects a group with its roles sorted, for scanGroup
+const groupColumns = `g.id, g.name, g.description, g.created_at,
+	ARRAY(SELECT role FROM group_roles WHERE group_id = g.id ORDER BY role)`
+
+func scanGroup(row pgx.Row, group *models.Group) error {
+	return row.Scan(&group.ID, &group.Name, &group.Description, &group.CreatedAt, &group.Roles)
+}
+
+// Group changes take effect on the next request, so like authentication
+// these functions use the primary rather than a possibly lagging replica.
+
+// CreateGroup inserts a group with its roles, failing with
+// ErrGroupNameTaken if another group has the same name in any case
+func CreateGroup(ctx context.Context, group *models.Group) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO groups (name, description) VALUES ($1, $2) RETURNING id, created_at`
+
+	err = tx.QueryRow(ctx, query, group.Name, group.Description).Scan(&group.ID, &group.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrGroupNameTaken
+		}
+		logging.FromContext(ctx).Error("Error creating group", "err", err)
+		return err
+	}
+	if err := setGroupRoles(ctx, tx, group.ID, group.Roles); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetGroup fetches a group by ID, or nil if there is none
+func GetGroup(ctx context.Context, id int) (*models.Group, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	var group models.Group
+	err := scanGroup(database.DB.QueryRow(ctx, `SELECT `+groupColumns+` FROM groups g WHERE g.id = $1`, id), &group)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logging.FromContext(ctx).Error("Error fetching group", "err", err)
+		return nil, err
+	}
+	return &group, nil
+}
+
+// ListGroups returns all groups ordered by name
+func ListGroups(ctx context.Context) ([]*models.Group, error) {
+	return queryGroups(ctx, `SELECT `+groupColumns+` FROM groups g ORDER BY LOWER(g.name)`)
+}
+
+// GetSubgroups returns the groups nested directly inside a group
+func GetSubgroups(ctx context.Context, id int) ([]*models.Group, error) {
+	return queryGroups(ctx, `SELECT `+groupColumns+` FROM groups g
+		JOIN group_subgroups s ON s.child_id = g.id WHERE s.parent_id = $1 ORDER BY LOWER(g.name)`, id)
+}
+
+// GetParentGroups returns the groups a group is nested directly inside
+func GetParentGroups(ctx context.Context, id int) ([]*models.Group, error) {
+	return queryGroups(ctx, `SELECT `+groupColumns+` FROM groups g
+		JOIN group_subgroups s ON s.parent_id = g.id WHERE s.child_id = $1 ORDER BY LOWER(g.name)`, id)
+}
+
+func queryGroups(ctx context.Context, query string, args ...interface{}) ([]*models.Group, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	rows, err := database.DB.Query(ctx, query, args...)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error querying groups", "err", err)
+		return nil, err
+	}
+	groups, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Group, error) {
+		var group models.Group
+		err := scanGroup(row, &group)
+		return &group, err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error scanning groups", "err", err)
+		return nil, err
+	}
+	return groups, nil
+}
+
+// UpdateGroup saves a group's name and description
+func UpdateGroup(ctx context.Context, group *models.Group) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	query := `UPDATE groups SET name = $1, description = $2 WHERE id = $3`
+
+	result, err := database.DB.Exec(ctx, query, group.Name, group.Description, group.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrGroupNameTaken
+		}
+		logging.FromContext(ctx).Error("Error updating group", "err", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+// DeleteGroup deletes a group. Its members and subgroups lose the roles it
+// granted, but are otherwise untouched. It fails with ErrLastAdmin if that
+// would leave no administrator.
+func DeleteGroup(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = keepAdmin(ctx, tx, func() error {
+		result, err := tx.Exec(ctx, `DELETE FROM groups WHERE id = $1`, id)
+		if err != nil {
+			logging.FromContext(ctx).Error("Error deleting group", "err", err)
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrGroupNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// keepAdmin makes a group change in tx and fails it with ErrLastAdmin if it
+// takes ADMIN from the last administrator. It takes the administrators lock,
+// so concurrent changes cannot each leave the other's admin as the last.
+func keepAdmin(ctx context.Context, tx pgx.Tx, change func() error) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, adminsLock); err != nil {
+		return err
+	}
+
+	before, err := adminExists(ctx, tx, 0)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error counting administrators", "err", err)
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	if !before {
+		return nil
+	}
+
+	after, err := adminExists(ctx, tx, 0)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error counting administrators", "err", err)
+		return err
+	}
+	if !after {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// SetGroupRoles replaces the roles a group grants. It fails with
+// ErrLastAdmin if that would leave no administrator.
+func SetGroupRoles(ctx context.Context, id int, roles []string) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = keepAdmin(ctx, tx, func() error {
+		// Lock the group so a concurrent delete cannot leave it half updated
+		if err := tx.QueryRow(ctx, `SELECT id FROM groups WHERE id = $1 FOR UPDATE`, id).Scan(&id); err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrGroupNotFound
+			}
+			return err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM group_roles WHERE group_id = $1`, id); err != nil {
+			return err
+		}
+		return setGroupRoles(ctx, tx, id, roles)
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func setGroupRoles(ctx context.Context, tx pgx.Tx, id int, roles []string) error {
+	query := `INSERT INTO group_roles (group_id, role) SELECT $1, UNNEST($2::VARCHAR[]) ON CONFLICT DO NOTHING`
+	if _, err := tx.Exec(ctx, query, id, roles); err != nil {
+		logging.FromContext(ctx).Error("Error setting group roles", "err", err)
+		return err
+	}
+	return nil
+}
+
+// AddGroupMembers adds users to a group and returns the IDs of those who
+// were not already members. IDs of users that do not exist are skipped.
+func AddGroupMembers(ctx context.Context, id int, userIDs []int) ([]int, error) {
+	query := `INSERT INTO group_members (group_id, user_id)
+			  SELECT $1, id FROM users WHERE id = ANY($2)
+			  ON CONFLICT DO NOTHING RETURNING user_id`
+	return changeGroupMembers(ctx, query, id, userIDs)
+}
+
+// RemoveGroupMembers removes users from a group and returns the IDs of
+// those who were members. It fails with ErrLastAdmin if that would leave no
+// administrator.
+func RemoveGroupMembers(ctx context.Context, id int, userIDs []int) ([]int, error) {
+	query := `DELETE FROM group_members WHERE group_id = $1 AND user_id = ANY($2) RETURNING user_id`
+	return changeGroupMembers(ctx, query, id, userIDs)
+}
+
+func changeGroupMembers(ctx context.Context, query string, id int, userIDs []int) ([]int, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var changed []int
+	err = keepAdmin(ctx, tx, func() error {
+		rows, err := tx.Query(ctx, query, id, userIDs)
+		if err == nil {
+			changed, err = pgx.CollectRows(rows, pgx.RowTo[int])
+		}
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return ErrGroupNotFound
+			}
+			logging.FromContext(ctx).Error("Error changing group members", "err", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// GetGroupMembers returns the users who are direct members of a group,
+// ordered by ID
+func GetGroupMembers(ctx context.Context, id int) ([]*models.User, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT u.id, u.name, u.email, u.role, u.version, u.updated_at FROM users u
+			  JOIN group_members m ON m.user_id = u.id
+			  WHERE m.group_id = $1 ORDER BY u.id`
+
+	rows, err := database.DB.Query(ctx, query, id)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error querying group members", "err", err)
+		return nil, err
+	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.User, error) {
+		var user models.User
+		err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt)
+		return &user, err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error scanning group members", "err", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// AddSubgroup nests child inside parent, so child's members also get
+// parent's roles. It fails with ErrGroupCycle if parent is child or is
+// already nested somewhere inside it.
+func AddSubgroup(ctx context.Context, parentID, childID int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Two concurrent additions could each pass the cycle check and together
+	// close a cycle, so nesting changes are serialised
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('group-subgroups'))`); err != nil {
+		return err
+	}
+
+	query := `WITH RECURSIVE descendants(id) AS (
+				  SELECT $2::INTEGER
+				  UNION
+				  SELECT s.child_id FROM group_subgroups s JOIN descendants d ON s.parent_id = d.id
+			  )
+			  SELECT EXISTS (SELECT 1 FROM descendants WHERE id = $1)`
+
+	var cycle bool
+	if err := tx.QueryRow(ctx, query, parentID, childID).Scan(&cycle); err != nil {
+		logging.FromContext(ctx).Error("Error checking group nesting", "err", err)
+		return err
+	}
+	if cycle {
+		return ErrGroupCycle
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO group_subgroups (parent_id, child_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, parentID, childID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrGroupNotFound
+		}
+		logging.FromContext(ctx).Error("Error adding subgroup", "err", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RemoveSubgroup un-nests child from parent, reporting whether it was
+// nested. It fails with ErrLastAdmin if that would leave no administrator.
+func RemoveSubgroup(ctx context.Context, parentID, childID int) (bool, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return false, errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var removed bool
+	err = keepAdmin(ctx, tx, func() error {
+		result, err := tx.Exec(ctx, `DELETE FROM group_subgroups WHERE parent_id = $1 AND child_id = $2`, parentID, childID)
+		if err != nil {
+			logging.FromContext(ctx).Error("Error removing subgroup", "err", err)
+			return err
+		}
+		removed = result.RowsAffected() > 0
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return removed, tx.Commit(ctx)
+}
+
+// GetEffectiveRoles resolves the roles each user holds: their own role plus
+// those of every group they belong to, directly or through nesting. Roles
+// are sorted and IDs with no user are absent from the map.
+func GetEffectiveRoles(ctx context.Context, userIDs []int) (map[int][]string, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	// UNION rather than UNION ALL stops the recursion at groups already
+	// visited, should a cycle ever get into the table
+	query := `WITH RECURSIVE memberships(user_id, group_id) AS (
+				  SELECT user_id, group_id FROM group_members WHERE user_id = ANY($1)
+				  UNION
+				  SELECT m.user_id, s.parent_id FROM memberships m JOIN group_subgroups s ON s.child_id = m.group_id
+			  )
+			  SELECT id, role FROM users WHERE id = ANY($1)
+			  UNION
+			  SELECT m.user_id, r.role FROM memberships m JOIN group_roles r ON r.group_id = m.group_id
+			  ORDER BY 1, 2`
+
+	rows, err := database.DB.Query(ctx, query, userIDs)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error resolving effective roles", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make(map[int][]string, len(userIDs))
+	for rows.Next() {
+		var id int
+		var role string
+		if err := rows.Scan(&id, &role); err != nil {
+			logging.FromContext(ctx).Error("Error scanning effective role", "err", err)
+			return nil, err
+		}
+		roles[id] = append(roles[id], role)
+	}
+	return roles, rows.Err()
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestNestedGroupRoles(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	if err := database.ConnectDB(context.Background(), databaseURL, database.DefaultPoolSettings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+
+	ctx := context.Background()
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	suffix := time.Now().UnixNano()
+	user := &models.User{Name: "Grouped", Email: fmt.Sprintf("grouped-%d@example.com", suffix), Role: models.RoleUser}
+	if err := CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	t.Cleanup(func() { DeleteUser(context.Background(), user.ID, nil, "test") })
+
+	admins := &models.Group{Name: fmt.Sprintf("admins-%d", suffix), Roles: []string{models.RoleAdmin}}
+	support := &models.Group{Name: fmt.Sprintf("support-%d", suffix), Roles: []string{models.RoleSupport}}
+	for _, g := range []*models.Group{admins, support} {
+		if err := CreateGroup(ctx, g); err != nil {
+			t.Fatalf("CreateGroup: %v", err)
+		}
+		t.Cleanup(func() { DeleteGroup(context.Background(), g.ID) })
+	}
+
+	if err := AddSubgroup(ctx, admins.ID, support.ID); err != nil {
+		t.Fatalf("AddSubgroup: %v", err)
+	}
+	if _, err := AddGroupMembers(ctx, support.ID, []int{user.ID}); err != nil {
+		t.Fatalf("AddGroupMembers: %v", err)
+	}
+
+	roles, err := GetEffectiveRoles(ctx, []int{user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{models.RoleAdmin, models.RoleSupport, models.RoleUser}; !reflect.DeepEqual(roles[user.ID], want) {
+		t.Errorf("effective roles %v, want %v", roles[user.ID], want)
+	}
+
+	if err := AddSubgroup(ctx, support.ID, admins.ID); !errors.Is(err, ErrGroupCycle) {
+		t.Errorf("expected nesting a group in its subgroup to fail with ErrGroupCycle, got %v", err)
+	}
+	if err := AddSubgroup(ctx, admins.ID, admins.ID); !errors.Is(err, ErrGroupCycle) {
+		t.Errorf("expected nesting a group in itself to fail with ErrGroupCycle, got %v", err)
+	}
+	if err := CreateGroup(ctx, &models.Group{Name: fmt.Sprintf("ADMINS-%d", suffix)}); !errors.Is(err, ErrGroupNameTaken) {
+		t.Errorf("expected a name differing only in case to be taken, got %v", err)
+	}
+}
+
+func TestGroupChangesKeepAnAdministrator(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	if err := database.ConnectDB(context.Background(), databaseURL, database.DefaultPoolSettings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+
+	ctx := context.Background()
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	// Deactivate everyone else for the test, so the group is the only
+	// source of ADMIN. Direct SQL, as the repository would refuse.
+	rows, err := database.DB.Query(ctx, `UPDATE users SET active = FALSE WHERE active RETURNING id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deactivated, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.DB.Exec(context.Background(), `UPDATE users SET active = TRUE WHERE id = ANY($1)`, deactivated)
+	})
+
+	suffix := time.Now().UnixNano()
+	user := &models.User{Name: "Group Admin", Email: fmt.Sprintf("group-admin-%d@example.com", suffix), Role: models.RoleUser}
+	if err := CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	admins := &models.Group{Name: fmt.Sprintf("last-admins-%d", suffix), Roles: []string{models.RoleAdmin}}
+	members := &models.Group{Name: fmt.Sprintf("last-members-%d", suffix)}
+	for _, g := range []*models.Group{admins, members} {
+		if err := CreateGroup(ctx, g); err != nil {
+			t.Fatalf("CreateGroup: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		database.DB.Exec(context.Background(), `DELETE FROM groups WHERE id = ANY($1)`, []int{admins.ID, members.ID})
+		database.DB.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, user.ID)
+	})
+	if err := AddSubgroup(ctx, admins.ID, members.ID); err != nil {
+		t.Fatalf("AddSubgroup: %v", err)
+	}
+	if _, err := AddGroupMembers(ctx, members.ID, []int{user.ID}); err != nil {
+		t.Fatalf("AddGroupMembers: %v", err)
+	}
+
+	changes := map[string]func() error{
+		"DeleteGroup parent": func() error { return DeleteGroup(ctx, admins.ID) },
+		"DeleteGroup child":  func() error { return DeleteGroup(ctx, members.ID) },
+		"SetGroupRoles":      func() error { return SetGroupRoles(ctx, admins.ID, []string{models.RoleSupport}) },
+		"RemoveGroupMembers": func() error { _, err := RemoveGroupMembers(ctx, members.ID, []int{user.ID}); return err },
+		"RemoveSubgroup":     func() error { _, err := RemoveSubgroup(ctx, admins.ID, members.ID); return err },
+	}
+	for name, change := range changes {
+		if err := change(); !errors.Is(err, ErrLastAdmin) {
+			t.Errorf("%s: expected ErrLastAdmin, got %v", name, err)
+		}
+	}
+
+	roles, err := GetEffectiveRoles(ctx, []int{user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{models.RoleAdmin, models.RoleUser}; !reflect.DeepEqual(roles[user.ID], want) {
+		t.Errorf("effective roles %v after refused changes, want %v", roles[user.ID], want)
+	}
+
+	// With another administrator the same change goes through
+	other := &models.User{Name: "Other Admin", Email: fmt.Sprintf("other-admin-%d@example.com", suffix), Role: models.RoleAdmin}
+	if err := CreateUser(ctx, other); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, other.ID) })
+	if _, err := RemoveSubgroup(ctx, admins.ID, members.ID); err != nil {
+		t.Errorf("RemoveSubgroup with another administrator: %v", err)
+	}
+}
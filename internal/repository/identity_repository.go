@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrIdentityNotFound is returned when an identity does not exist or belongs to another user
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrIdentityLinked is returned when a provider subject is already linked to a user
+	ErrIdentityLinked = errors.New("identity is already linked to an account")
+	// ErrLastIdentity is returned when unlinking would leave a user without any login method
+	ErrLastIdentity = errors.New("cannot remove the last login method")
+)
+
+const identityColumns = `id, user_id, provider, subject, email, linked_at`
+
+func scanIdentity(row pgx.Row, identity *models.Identity) error {
+	return row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.LinkedAt)
+}
+
+// GetIdentity fetches the identity for a provider subject, or nil if none is linked
+func GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	var identity models.Identity
+	err := scanIdentity(database.DB.QueryRow(ctx, query, provider, subject), &identity)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logging.FromContext(ctx).Error("Error fetching identity", "err", err)
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// GetIdentitiesByUser lists every identity linked to a user
+func GetIdentitiesByUser(ctx context.Context, userID int) ([]*models.Identity, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE user_id = $1 ORDER BY linked_at`
+
+	rows, err := database.DB.Query(ctx, query, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error querying identities", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []*models.Identity{}
+	for rows.Next() {
+		var identity models.Identity
+		if err := scanIdentity(rows, &identity); err != nil {
+			logging.FromContext(ctx).Error("Error scanning identity row", "err", err)
+			return nil, err
+		}
+		identities = append(identities, &identity)
+	}
+	return identities, rows.Err()
+}
+
+// LinkIdentity attaches a provider subject to an existing user
+func LinkIdentity(ctx context.Context, identity *models.Identity) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	return insertIdentity(ctx, database.DB, identity)
+}
+
+// CreateUserWithIdentity creates a user and its first identity in one transaction
+func CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.Identity) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO users (name, email, role) VALUES ($1, $2, $3) RETURNING id, version, updated_at`
+	if err := tx.QueryRow(ctx, query, user.Name, user.Email, user.Role).Scan(&user.ID, &user.Version, &user.UpdatedAt); err != nil {
+		logging.FromContext(ctx).Error("Error creating user", "err", err)
+		return err
+	}
+
+	identity.UserID = user.ID
+	if err := insertIdentity(ctx, tx, identity); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UnlinkIdentity removes one of a user's identities, refusing to remove the last one
+func UnlinkIdentity(ctx context.Context, userID, identityID int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the user row so concurrent unlinks cannot both pass the count check
+	var id int
+	if err := tx.QueryRow(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id); err != nil {
+		if err == pgx.ErrNoRows {
+			return errors.New("user not found")
+		}
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastIdentity
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, identityID, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error unlinking identity", "err", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrIdentityNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertIdentity(ctx context.Context, db queryRower, identity *models.Identity) error {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)
+			  RETURNING id, linked_at`
+
+	err := db.QueryRow(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.LinkedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrIdentityLinked
+		}
+		logging.FromContext(ctx).Error("Error linking identity", "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const jobRunColumns = `id, job, scheduled_at, runner, status, affected, error, started_at, finished_at`
+
+func scanJobRun(row pgx.Row, run *models.JobRun) error {
+	return row.Scan(&run.ID, &run.Job, &run.ScheduledAt, &run.Runner, &run.Status, &run.Affected, &run.Error, &run.StartedAt, &run.FinishedAt)
+}
+
+// TryJobLock takes the advisory lock of a job unless another instance holds
+// it, and returns a function releasing it. ok is false when the lock is held
+// elsewhere. The lock lives as long as the connection it was taken on, so
+// the connection is kept out of the pool until it is released.
+func TryJobLock(ctx context.Context, job string) (unlock func(), ok bool, err error) {
+	if database.DB == nil {
+		return nil, false, errors.New("database connection is not initialized")
+	}
+
+	conn, err := database.DB.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := "job:" + job
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, key).Scan(&ok); err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+
+	unlock = func() {
+		ctx, cancel := withTimeout(context.Background(), opWrite)
+		defer cancel()
+
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, key); err != nil {
+			// Closing the connection is the only other way to free the lock
+			logging.FromContext(ctx).Error("Error releasing job lock", "job", job, "err", err)
+			conn.Hijack().Close(ctx)
+			return
+		}
+		conn.Release()
+	}
+	return unlock, true, nil
+}
+
+// StartJobRun records that run has started and fills in its ID and start
+// time. It reports false, recording nothing, if the job already ran for
+// the same scheduled time.
+func StartJobRun(ctx context.Context, run *models.JobRun) (bool, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return false, errors.New("database connection is not initialized")
+	}
+
+	query := `INSERT INTO job_runs (job, scheduled_at, runner, status) VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (job, scheduled_at) DO NOTHING RETURNING id, started_at`
+
+	run.Status = models.JobRunning
+	err := database.DB.QueryRow(ctx, query, run.Job, run.ScheduledAt, run.Runner, run.Status).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		logging.FromContext(ctx).Error("Error recording job run", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// FinishJobRun records the outcome of a run
+func FinishJobRun(ctx context.Context, run *models.JobRun) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	query := `UPDATE job_runs SET status = $1, affected = $2, error = $3, finished_at = NOW()
+			  WHERE id = $4 RETURNING finished_at`
+
+	err := database.DB.QueryRow(ctx, query, run.Status, run.Affected, run.Error, run.ID).Scan(&run.FinishedAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error recording job outcome", "err", err)
+		return err
+	}
+	return nil
+}
+
+// GetJobRuns returns the most recent runs, optionally of one job and with
+// one status
+func GetJobRuns(ctx context.Context, job, status string, limit int) ([]*models.JobRun, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT ` + jobRunColumns + ` FROM job_runs
+			  WHERE ($1 = '' OR job = $1) AND ($2 = '' OR status = $2)
+			  ORDER BY started_at DESC, id DESC LIMIT $3`
+
+	rows, err := database.DB.Query(ctx, query, job, status, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error listing job runs", "err", err)
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.JobRun, error) {
+		var run models.JobRun
+		err := scanJobRun(row, &run)
+		return &run, err
+	})
+}
+
+// GetLatestJobRuns returns the latest run of each job, by job name
+func GetLatestJobRuns(ctx context.Context) (map[string]*models.JobRun, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT DISTINCT ON (job) ` + jobRunColumns + ` FROM job_runs ORDER BY job, started_at DESC, id DESC`
+
+	rows, err := database.DB.Query(ctx, query)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error listing latest job runs", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[string]*models.JobRun)
+	for rows.Next() {
+		var run models.JobRun
+		if err := scanJobRun(rows, &run); err != nil {
+			return nil, err
+		}
+		latest[run.Job] = &run
+	}
+	return latest, rows.Err()
+}
+
+// PurgeJobRuns deletes the history of runs started before the cutoff and
+// returns how many were removed
+func PurgeJobRuns(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, opBulk)
+	defer cancel()
+
+	if database.DB == nil {
+		return 0, errors.New("database connection is not initialized")
+	}
+
+	result, err := database.DB.Exec(ctx, `DELETE FROM job_runs WHERE started_at < $1`, before)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error purging job runs", "err", err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
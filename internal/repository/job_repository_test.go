@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/models"
+)
+
+func TestJobRunsRunOncePerSlot(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	if err := database.ConnectDB(context.Background(), databaseURL, database.DefaultPoolSettings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+
+	ctx := context.Background()
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	job := fmt.Sprintf("test-job-%d", time.Now().UnixNano())
+	t.Cleanup(func() { database.DB.Exec(context.Background(), `DELETE FROM job_runs WHERE job = $1`, job) })
+
+	unlock, ok, err := TryJobLock(ctx, job)
+	if err != nil || !ok {
+		t.Fatalf("expected to take the job lock, got %v (%v)", ok, err)
+	}
+	if _, ok, err := TryJobLock(ctx, job); err != nil || ok {
+		t.Errorf("expected the lock to be held, got %v (%v)", ok, err)
+	}
+	unlock()
+
+	slot := time.Now().UTC().Truncate(time.Minute)
+	run := &models.JobRun{Job: job, ScheduledAt: slot, Runner: "a"}
+	if started, err := StartJobRun(ctx, run); err != nil || !started {
+		t.Fatalf("expected the first run to start, got %v (%v)", started, err)
+	}
+	if started, err := StartJobRun(ctx, &models.JobRun{Job: job, ScheduledAt: slot, Runner: "b"}); err != nil || started {
+		t.Errorf("expected a second run for the same slot to be skipped, got %v (%v)", started, err)
+	}
+
+	run.Status, run.Error = models.JobFailed, "boom"
+	if err := FinishJobRun(ctx, run); err != nil {
+		t.Fatalf("FinishJobRun: %v", err)
+	}
+	runs, err := GetJobRuns(ctx, job, models.JobFailed, 10)
+	if err != nil {
+		t.Fatalf("GetJobRuns: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Error != "boom" || runs[0].FinishedAt == nil {
+		t.Errorf("expected the failed run to be listed, got %+v", runs)
+	}
+}
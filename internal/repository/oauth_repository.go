@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateOAuthClient registers a new relying party
+func CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	query := `INSERT INTO oauth_clients (client_id, secret_hash, name, redirect_uris)
+			  VALUES ($1, NULLIF($2, ''), $3, $4) RETURNING id, created_at`
+
+	err := database.DB.QueryRow(ctx, query, client.ClientID, client.SecretHash, client.Name, client.RedirectURIs).
+		Scan(&client.ID, &client.CreatedAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error creating OAuth client", "err", err)
+		return err
+	}
+	return nil
+}
+
+// GetOAuthClient fetches a registered client by its client_id, or nil if unknown
+func GetOAuthClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id, client_id, COALESCE(secret_hash, ''), name, redirect_uris, created_at
+			  FROM oauth_clients WHERE client_id = $1`
+
+	var client models.OAuthClient
+	err := database.DB.QueryRow(ctx, query, clientID).Scan(
+		&client.ID, &client.ClientID, &client.SecretHash, &client.Name, &client.RedirectURIs, &client.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logging.FromContext(ctx).Error("Error fetching OAuth client", "err", err)
+		return nil, err
+	}
+	return &client, nil
+}
+
+// SaveAuthorizationCode stores a newly issued authorization code
+func SaveAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	query := `INSERT INTO oauth_authorization_codes
+			  (code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+
+	err := database.DB.QueryRow(ctx, query,
+		code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope,
+		code.Nonce, code.CodeChallenge, code.AuthTime, code.ExpiresAt,
+	).Scan(&code.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error saving authorization code", "err", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeAuthorizationCode marks an unexpired code as used and returns it.
+// It returns nil if the code is unknown, expired or was already redeemed.
+func ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `UPDATE oauth_authorization_codes SET is_used = TRUE
+			  WHERE code_hash = $1 AND is_used = FALSE AND expires_at > NOW()
+			  RETURNING id, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at, is_used`
+
+	var code models.AuthorizationCode
+	err := database.DB.QueryRow(ctx, query, codeHash).Scan(
+		&code.ID, &code.ClientID, &code.UserID, &code.RedirectURI, &code.Scope,
+		&code.Nonce, &code.CodeChallenge, &code.AuthTime, &code.ExpiresAt, &code.IsUsed,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logging.FromContext(ctx).Error("Error consuming authorization code", "err", err)
+		return nil, err
+	}
+	code.CodeHash = codeHash
+	return &code, nil
+}
@@ -3,17 +3,18 @@ package repository
 import (
 	"context"
 	"errors"
-	"log"
 	"time"
 	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
 	"user-management-service/internal/models"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // SaveOTP stores a new OTP in the database
-func SaveOTP(otp *models.OTP) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func SaveOTP(ctx context.Context, otp *models.OTP) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
 	defer cancel()
 
 	if database.DB == nil {
@@ -24,15 +25,17 @@ func SaveOTP(otp *models.OTP) error {
 
 	err := database.DB.QueryRow(ctx, query, otp.Email, otp.OTP, otp.ExpiresAt).Scan(&otp.ID)
 	if err != nil {
-		log.Printf("Error saving OTP: %v", err)
+		logging.FromContext(ctx).Error("Error saving OTP", "err", err)
 		return err
 	}
 	return nil
 }
 
-// GetLatestOTP retrieves the most recent OTP for an email
-func GetLatestOTP(email string) (*models.OTP, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// GetLatestOTP retrieves the most recent OTP for an email. It may be served
+// by the read replica; verification must pin the primary with
+// database.WithPrimary so a just-issued or just-used OTP is seen.
+func GetLatestOTP(ctx context.Context, email string) (*models.OTP, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
 	defer cancel()
 
 	if database.DB == nil {
@@ -43,22 +46,48 @@ func GetLatestOTP(email string) (*models.OTP, error) {
 			  WHERE email = $1 ORDER BY created_at DESC LIMIT 1`
 
 	var otp models.OTP
-	err := database.DB.QueryRow(ctx, query, email).Scan(
-		&otp.ID, &otp.Email, &otp.OTP, &otp.ExpiresAt, &otp.IsUsed, &otp.AttemptCount, &otp.CreatedAt,
-	)
+	err := database.Read(ctx, func(db *pgxpool.Pool) error {
+		return db.QueryRow(ctx, query, email).Scan(
+			&otp.ID, &otp.Email, &otp.OTP, &otp.ExpiresAt, &otp.IsUsed, &otp.AttemptCount, &otp.CreatedAt,
+		)
+	})
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil // No OTP found
 		}
-		log.Printf("Error fetching OTP: %v", err)
+		logging.FromContext(ctx).Error("Error fetching OTP", "err", err)
 		return nil, err
 	}
 	return &otp, nil
 }
 
+// GetOTPsByEmail lists every OTP issued to an email, newest first
+func GetOTPsByEmail(ctx context.Context, email string) ([]*models.OTP, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id, email, otp, expires_at, is_used, attempt_count, created_at FROM otps
+			  WHERE LOWER(email) = LOWER($1) ORDER BY created_at DESC`
+
+	rows, err := database.DB.Query(ctx, query, email)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error listing OTPs", "err", err)
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.OTP, error) {
+		var otp models.OTP
+		err := row.Scan(&otp.ID, &otp.Email, &otp.OTP, &otp.ExpiresAt, &otp.IsUsed, &otp.AttemptCount, &otp.CreatedAt)
+		return &otp, err
+	})
+}
+
 // IncrementOTPAttempts increases the attempt count for an OTP
-func IncrementOTPAttempts(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func IncrementOTPAttempts(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
 	defer cancel()
 
 	if database.DB == nil {
@@ -71,8 +100,8 @@ func IncrementOTPAttempts(id int) error {
 }
 
 // MarkOTPAsUsed marks an OTP as used
-func MarkOTPAsUsed(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func MarkOTPAsUsed(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
 	defer cancel()
 
 	if database.DB == nil {
@@ -83,3 +112,41 @@ func MarkOTPAsUsed(id int) error {
 	_, err := database.DB.Exec(ctx, query, id)
 	return err
 }
+
+// PurgeUntriedOTPs deletes OTPs that expired before the cutoff without
+// being used or tried, and returns how many were removed. Tried and used
+// OTPs are the record of login attempts and are left to PurgeOTPs.
+func PurgeUntriedOTPs(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, opBulk)
+	defer cancel()
+
+	if database.DB == nil {
+		return 0, errors.New("database connection is not initialized")
+	}
+
+	query := `DELETE FROM otps WHERE expires_at < $1 AND NOT is_used AND attempt_count = 0`
+	result, err := database.DB.Exec(ctx, query, before)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error purging untried OTPs", "err", err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// PurgeOTPs deletes OTPs that expired or were used before the cutoff and returns how many were removed
+func PurgeOTPs(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, opBulk)
+	defer cancel()
+
+	if database.DB == nil {
+		return 0, errors.New("database connection is not initialized")
+	}
+
+	query := `DELETE FROM otps WHERE expires_at < $1 OR (is_used AND created_at < $1)`
+	result, err := database.DB.Exec(ctx, query, before)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error purging OTPs", "err", err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Result counts for SearchUsers
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// ErrEmptySearch is returned by SearchUsers for a query with nothing to match
+var ErrEmptySearch = errors.New("search query is empty")
+
+// searchCandidates bounds how many users each kind of match contributes
+// before ranking, so short, common queries stay fast on large tables
+const searchCandidates = 200
+
+// userSearchVector must stay the expression of idx_users_search_vector
+const userSearchVector = `(setweight(to_tsvector('simple', name), 'A') || setweight(to_tsvector('simple', email), 'B'))`
+
+// SearchUsers finds the users whose name or email matches query and returns
+// up to limit of them, best first. Users are matched by prefix and by the
+// words of the query in any order; queries of three or more characters also
+// match substrings and similar spellings, so small typos still find them.
+// Each kind of match is served by an index, see
+// migrations/20261019_11_create_user_search_indexes.sql.
+func SearchUsers(ctx context.Context, query string, limit int) ([]*models.UserSearchResult, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	term := strings.ToLower(strings.TrimSpace(query))
+	words := strings.Fields(term)
+	if len(words) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	// Each branch is capped, in an order its index returns cheaply, and the
+	// union is then ranked as a whole. Trigram indexes cannot narrow shorter
+	// queries, so those skip the substring and similarity branches.
+	sql := `WITH candidates AS (
+				(SELECT id FROM users WHERE LOWER(name) COLLATE "C" LIKE $2 ORDER BY LOWER(name) COLLATE "C" LIMIT $5)
+				UNION
+				(SELECT id FROM users WHERE LOWER(email) COLLATE "C" LIKE $2 ORDER BY LOWER(email) COLLATE "C" LIMIT $5)
+				UNION
+				(SELECT id FROM users WHERE ` + userSearchVector + ` @@ to_tsquery('simple', $3) LIMIT $5)
+				UNION
+				(SELECT id FROM users WHERE char_length($1) >= 3 AND (LOWER(name) LIKE $4 OR LOWER(email) LIKE $4) LIMIT $5)
+				UNION
+				(SELECT id FROM users WHERE char_length($1) >= 3 AND (LOWER(name) % $1 OR LOWER(email) % $1) LIMIT $5)
+			)
+			SELECT id, name, email, role, version, updated_at,
+				CASE WHEN LOWER(name) LIKE $2 OR LOWER(email) LIKE $2 THEN 1 ELSE 0 END
+				+ ts_rank(` + userSearchVector + `, to_tsquery('simple', $3))
+				+ GREATEST(similarity(LOWER(name), $1), similarity(LOWER(email), $1)) AS score
+			FROM users JOIN candidates USING (id)
+			ORDER BY score DESC, id
+			LIMIT $6`
+
+	var results []*models.UserSearchResult
+	err := database.Read(ctx, func(db *pgxpool.Pool) error {
+		rows, err := db.Query(ctx, sql, term, escapeLike(term)+"%", searchTSQuery(words), "%"+escapeLike(term)+"%", searchCandidates, limit)
+		if err != nil {
+			return err
+		}
+		results, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.UserSearchResult, error) {
+			var user models.User
+			var score float64
+			err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt, &score)
+			return &models.UserSearchResult{User: &user, Score: score}, err
+		})
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error searching users", "err", err)
+		return nil, err
+	}
+
+	for _, result := range results {
+		result.Highlights = highlights(result.User, words)
+	}
+	return results, nil
+}
+
+// searchTSQuery matches documents with a word starting with each of words
+func searchTSQuery(words []string) string {
+	parts := make([]string, len(words))
+	for i, w := range words {
+		w = strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(w)
+		parts[i] = `'` + w + `':*`
+	}
+	return strings.Join(parts, " & ")
+}
+
+// highlights lists the fields of user in which words occur, and where
+func highlights(user *models.User, words []string) []models.Highlight {
+	result := []models.Highlight{}
+	for _, field := range []struct{ name, value string }{{"name", user.Name}, {"email", user.Email}} {
+		if matches := matchRanges(field.value, words); len(matches) > 0 {
+			result = append(result, models.Highlight{Field: field.name, Value: field.value, Matches: matches})
+		}
+	}
+	return result
+}
+
+// matchRanges finds every case-insensitive occurrence of words in value,
+// merging those that overlap. Misspellings found by similarity have none.
+func matchRanges(value string, words []string) []models.MatchRange {
+	// Lowercase rune by rune so offsets stay those of value
+	text := []rune(value)
+	for i, r := range text {
+		text[i] = unicode.ToLower(r)
+	}
+
+	var ranges []models.MatchRange
+	for _, word := range words {
+		w := []rune(word)
+		for start := 0; start+len(w) <= len(text); start++ {
+			if string(text[start:start+len(w)]) == word {
+				ranges = append(ranges, models.MatchRange{Start: start, End: start + len(w)})
+			}
+		}
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			last.End = max(last.End, r.End)
+		} else {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
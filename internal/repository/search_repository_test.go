@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"user-management-service/internal/models"
+)
+
+func TestMatchRanges(t *testing.T) {
+	tests := []struct {
+		value string
+		words []string
+		want  []models.MatchRange
+	}{
+		{"Jane Doe", []string{"ja"}, []models.MatchRange{{Start: 0, End: 2}}},
+		{"Jane Doe", []string{"doe", "jane"}, []models.MatchRange{{Start: 0, End: 4}, {Start: 5, End: 8}}},
+		{"anna.banana@example.com", []string{"ana"}, []models.MatchRange{{Start: 6, End: 11}}},
+		{"Ångström Åsa", []string{"åsa"}, []models.MatchRange{{Start: 9, End: 12}}},
+		{"Jane Doe", []string{"jnae"}, nil},
+	}
+	for _, tt := range tests {
+		if got := matchRanges(tt.value, tt.words); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matchRanges(%q, %q) = %v, want %v", tt.value, tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTSQuery(t *testing.T) {
+	if got, want := searchTSQuery([]string{"o'brien", `a\b`}), `'o''brien':* & 'a\\b':*`; got != want {
+		t.Errorf("searchTSQuery = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrUserDeactivated is returned by CreateSession for users who may not sign in
+var ErrUserDeactivated = errors.New("account is deactivated")
+
+// CreateSession records a newly issued login session. Deactivated users get
+// ErrUserDeactivated instead.
+func CreateSession(ctx context.Context, session *models.Session) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	query := `INSERT INTO sessions (id, user_id, expires_at)
+			  SELECT $1::VARCHAR, id, $3::TIMESTAMPTZ FROM users WHERE id = $2 AND active RETURNING created_at`
+
+	err := database.DB.QueryRow(ctx, query, session.ID, session.UserID, session.ExpiresAt).Scan(&session.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrUserDeactivated
+		}
+		logging.FromContext(ctx).Error("Error creating session", "err", err)
+		return err
+	}
+	return nil
+}
+
+// GetSession fetches a session by ID, or nil if unknown
+func GetSession(ctx context.Context, id string) (*models.Session, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id, user_id, created_at, expires_at, revoked_at FROM sessions WHERE id = $1`
+
+	var session models.Session
+	err := database.DB.QueryRow(ctx, query, id).Scan(
+		&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logging.FromContext(ctx).Error("Error fetching session", "err", err)
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSessionsByUser lists a user's sessions, revoked and expired ones
+// included, newest first
+func GetSessionsByUser(ctx context.Context, userID int) ([]*models.Session, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id, user_id, created_at, expires_at, revoked_at FROM sessions
+			  WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := database.DB.Query(ctx, query, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error listing sessions", "err", err)
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Session, error) {
+		var session models.Session
+		err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt)
+		return &session, err
+	})
+}
+
+// PurgeSessions deletes sessions that expired before the cutoff and returns
+// how many were removed. Their tokens have expired too, so revoked ones no
+// longer need remembering either.
+func PurgeSessions(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, opBulk)
+	defer cancel()
+
+	if database.DB == nil {
+		return 0, errors.New("database connection is not initialized")
+	}
+
+	result, err := database.DB.Exec(ctx, `DELETE FROM sessions WHERE expires_at < $1`, before)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error purging sessions", "err", err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// RevokeSession revokes a single session
+func RevokeSession(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	result, err := database.DB.Exec(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error revoking session", "err", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("session not found or already revoked")
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of a user and returns how many were revoked
+func RevokeUserSessions(ctx context.Context, userID int) (int64, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return 0, errors.New("database connection is not initialized")
+	}
+
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()`
+
+	result, err := database.DB.Exec(ctx, query, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error revoking sessions", "err", err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
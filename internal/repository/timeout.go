@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// TimeoutPolicy bounds how long repository calls may run. Each call derives
+// its deadline from the caller's context, so a cancelled request aborts its
+// query, and a caller deadline sooner than the policy still wins.
+type TimeoutPolicy struct {
+	Read  time.Duration
+	Write time.Duration
+	// Bulk covers full-table reads and maintenance such as OTP purges
+	Bulk time.Duration
+}
+
+// DefaultTimeoutPolicy matches the fixed 5 second timeout used before the policy was configurable
+var DefaultTimeoutPolicy = TimeoutPolicy{
+	Read:  5 * time.Second,
+	Write: 5 * time.Second,
+	Bulk:  60 * time.Second,
+}
+
+var policy atomic.Pointer[TimeoutPolicy]
+
+func init() {
+	SetTimeoutPolicy(DefaultTimeoutPolicy)
+}
+
+// SetTimeoutPolicy replaces the policy. Zero durations keep their defaults.
+func SetTimeoutPolicy(p TimeoutPolicy) {
+	if p.Read <= 0 {
+		p.Read = DefaultTimeoutPolicy.Read
+	}
+	if p.Write <= 0 {
+		p.Write = DefaultTimeoutPolicy.Write
+	}
+	if p.Bulk <= 0 {
+		p.Bulk = DefaultTimeoutPolicy.Bulk
+	}
+	policy.Store(&p)
+}
+
+type operation int
+
+const (
+	opRead operation = iota
+	opWrite
+	opBulk
+)
+
+type timeoutOverrideKey struct{}
+
+// WithTimeout overrides the policy for repository calls made with the
+// returned context, e.g. to give a one-off import more time than the bulk
+// default. Unlike context.WithTimeout it can lengthen the policy deadline.
+func WithTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutOverrideKey{}, d)
+}
+
+// withTimeout bounds a single repository call according to the policy
+func withTimeout(ctx context.Context, op operation) (context.Context, context.CancelFunc) {
+	if d, ok := ctx.Value(timeoutOverrideKey{}).(time.Duration); ok && d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+
+	p := policy.Load()
+	switch op {
+	case opRead:
+		return context.WithTimeout(ctx, p.Read)
+	case opBulk:
+		return context.WithTimeout(ctx, p.Bulk)
+	default:
+		return context.WithTimeout(ctx, p.Write)
+	}
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/models"
+)
+
+func TestWithTimeoutAppliesPolicy(t *testing.T) {
+	SetTimeoutPolicy(TimeoutPolicy{Read: time.Second, Bulk: time.Hour})
+	t.Cleanup(func() { SetTimeoutPolicy(DefaultTimeoutPolicy) })
+
+	ctx, cancel := withTimeout(context.Background(), opRead)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > time.Second {
+		t.Errorf("read deadline %s away, want at most 1s", time.Until(deadline))
+	}
+
+	ctx, cancel = withTimeout(context.Background(), opWrite)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > DefaultTimeoutPolicy.Write {
+		t.Errorf("unset write timeout should keep its default, got %s", time.Until(deadline))
+	}
+}
+
+func TestWithTimeoutKeepsSoonerCallerDeadline(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ctx, cancel := withTimeout(parent, opBulk)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > 10*time.Millisecond {
+		t.Errorf("caller deadline was extended to %s", time.Until(deadline))
+	}
+}
+
+func TestWithTimeoutOverride(t *testing.T) {
+	ctx, cancel := withTimeout(WithTimeout(context.Background(), 10*time.Minute), opRead)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) < 9*time.Minute {
+		t.Errorf("override ignored, deadline %s away", time.Until(deadline))
+	}
+}
+
+// lockUser connects to TEST_DATABASE_URL and holds a row lock on a new user
+// until the test ends, so queries touching that row block
+func lockUser(t *testing.T) *models.User {
+	t.Helper()
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	if err := database.ConnectDB(context.Background(), databaseURL, database.DefaultPoolSettings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+
+	ctx := context.Background()
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	user := &models.User{Name: "Locked", Email: fmt.Sprintf("locked-%d@example.com", time.Now().UnixNano()), Role: models.RoleUser}
+	if err := CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	t.Cleanup(func() { DeleteUser(context.Background(), user.ID, nil, "test") })
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback(context.Background()) })
+	if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestCancelledRequestAbortsQuery(t *testing.T) {
+	user := lockUser(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		_, err := SetUserRole(ctx, user.ID, models.RoleSupport)
+		done <- err
+	}()
+
+	// Simulate the client disconnecting while the update waits on the lock
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("query succeeded despite cancellation")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("query returned %s after cancellation", elapsed)
+		}
+	case <-time.After(DefaultTimeoutPolicy.Write):
+		t.Fatal("cancelled query kept running until the policy timeout")
+	}
+}
+
+func TestPolicyTimeoutAbortsQuery(t *testing.T) {
+	user := lockUser(t)
+	SetTimeoutPolicy(TimeoutPolicy{Write: 100 * time.Millisecond})
+	t.Cleanup(func() { SetTimeoutPolicy(DefaultTimeoutPolicy) })
+
+	start := time.Now()
+	if _, err := SetUserRole(context.Background(), user.ID, models.RoleSupport); err == nil {
+		t.Fatal("query succeeded despite the row lock")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("query ran for %s with a 100ms write timeout", elapsed)
+	}
+}
@@ -3,159 +3,561 @@ package repository
 import (
 	"context"
 	"errors"
-	"log"
+	"strings"
 	"time"
 
 	"user-management-service/internal/database"
+	"user-management-service/internal/logging"
 	"user-management-service/internal/models"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // CreateUser inserts a new user into the database
-func CreateUser(user *models.User) error {
-	// Use a 5-second timeout for the database operation
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func CreateUser(ctx context.Context, user *models.User) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
 	defer cancel()
 
 	if database.DB == nil {
 		return errors.New("database connection is not initialized")
 	}
 
-	query := `INSERT INTO users (name, email, role) VALUES ($1, $2, $3) RETURNING id`
+	query := `INSERT INTO users (name, email, role) VALUES ($1, $2, $3) RETURNING id, version, updated_at`
 
-	err := database.DB.QueryRow(ctx, query, user.Name, user.Email, user.Role).Scan(&user.ID)
+	err := database.DB.QueryRow(ctx, query, user.Name, user.Email, user.Role).Scan(&user.ID, &user.Version, &user.UpdatedAt)
 	if err != nil {
-		log.Printf("Error creating user: %v", err)
+		logging.FromContext(ctx).Error("Error creating user", "err", err)
 		return err
 	}
 	return nil
 }
 
 // GetUserByID fetches a user by their ID
-func GetUserByID(id int) (*models.User, error) {
-	// Use a 5-second timeout for the database operation
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
 	defer cancel()
 
 	if database.DB == nil {
 		return nil, errors.New("database connection is not initialized")
 	}
 
-	query := `SELECT id, name, email, role FROM users WHERE id = $1`
+	query := `SELECT id, name, email, role, version, updated_at FROM users WHERE id = $1`
 
 	var user models.User
-	err := database.DB.QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Role)
+	err := database.Read(ctx, func(db *pgxpool.Pool) error {
+		return db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt)
+	})
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil // User not found
 		}
-		log.Printf("Error fetching user: %v", err)
+		logging.FromContext(ctx).Error("Error fetching user", "err", err)
 		return nil, err
 	}
 	return &user, nil
 }
 
 // GetAllUsers retrieves all users from the database
-func GetAllUsers() ([]*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func GetAllUsers(ctx context.Context) ([]*models.User, error) {
+	ctx, cancel := withTimeout(ctx, opBulk)
 	defer cancel()
 
 	if database.DB == nil {
 		return nil, errors.New("database connection is not initialized")
 	}
 
-	query := `SELECT id, name, email, role FROM users`
+	query := `SELECT id, name, email, role, version, updated_at FROM users`
 
-	rows, err := database.DB.Query(ctx, query)
+	var users []*models.User
+	err := database.Read(ctx, func(db *pgxpool.Pool) error {
+		rows, err := db.Query(ctx, query)
+		if err != nil {
+			return err
+		}
+		users, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.User, error) {
+			var user models.User
+			err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt)
+			return &user, err
+		})
+		return err
+	})
 	if err != nil {
-		log.Printf("Error querying users: %v", err)
+		logging.FromContext(ctx).Error("Error querying users", "err", err)
 		return nil, err
 	}
-	defer rows.Close()
 
-	var users []*models.User
-	for rows.Next() {
-		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Role); err != nil {
-			log.Printf("Error scanning user row: %v", err)
+	return users, nil
+}
+
+// UpdateUser updates an existing user's name and email and loads the rest
+// of the user. Roles are changed with SetUserRole.
+func UpdateUser(ctx context.Context, user *models.User) error {
+	updated, err := PatchUser(ctx, user.ID, UserPatch{Name: &user.Name, Email: &user.Email}, nil)
+	if err != nil {
+		return err
+	}
+	*user = *updated
+	return nil
+}
+
+// ErrVersionConflict is returned by PatchUser when the user has changed
+// since the version the caller expected
+var ErrVersionConflict = errors.New("the user was changed by someone else; reload it and try again")
+
+// UserPatch is a partial update. Nil fields are left unchanged.
+type UserPatch struct {
+	Name  *string
+	Email *string
+}
+
+// PatchUser applies a partial update and returns the updated user. With an
+// expectedVersion it fails with ErrVersionConflict unless the user is still
+// at that version, so concurrent edits cannot silently overwrite each other.
+// A new email takes effect at once, moving the email identity and revoking
+// sessions as ConfirmEmailChange does; the API instead asks the new address
+// to confirm it.
+func PatchUser(ctx context.Context, id int, patch UserPatch, expectedVersion *int) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE users u SET name = COALESCE($1, u.name), email = COALESCE($2, u.email)
+			  FROM (SELECT id, email FROM users WHERE id = $3 FOR UPDATE) old
+			  WHERE u.id = old.id AND ($4::INTEGER IS NULL OR u.version = $4)
+			  RETURNING u.id, u.name, u.email, u.role, u.version, u.updated_at, old.email`
+
+	var user models.User
+	var previous string
+	err = tx.QueryRow(ctx, query, patch.Name, patch.Email, id, expectedVersion).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt, &previous)
+	if err == pgx.ErrNoRows && expectedVersion != nil {
+		// Tell a stale version apart from a missing user
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, id).Scan(&exists); err != nil {
 			return nil, err
 		}
-		users = append(users, &user)
+		if exists {
+			return nil, ErrVersionConflict
+		}
 	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating user rows: %v", err)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrEmailTaken
+		}
+		logging.FromContext(ctx).Error("Error updating user", "err", err)
 		return nil, err
 	}
 
-	return users, nil
+	if previous != user.Email {
+		if err := moveEmail(ctx, tx, user.ID, previous, user.Email); err != nil {
+			return nil, err
+		}
+	}
+	return &user, tx.Commit(ctx)
 }
 
-// UpdateUser updates an existing user's information
-func UpdateUser(user *models.User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ErrInvalidEmailChangeToken is returned by ConfirmEmailChange when no
+// pending email change has the token, or it has expired
+var ErrInvalidEmailChangeToken = errors.New("the email change token is invalid or has expired")
+
+// RequestEmailChange records newEmail as the user's pending email, to be
+// confirmed with the token whose hash is given before expiresAt. It replaces
+// any earlier request and returns the user.
+func RequestEmailChange(ctx context.Context, id int, newEmail, tokenHash string, expiresAt time.Time) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
 	defer cancel()
 
 	if database.DB == nil {
-		return errors.New("database connection is not initialized")
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `UPDATE users SET pending_email = $1, email_change_token_hash = $2, email_change_expires_at = $3
+			  WHERE id = $4 RETURNING id, name, email, role, version, updated_at`
+
+	var user models.User
+	err := database.DB.QueryRow(ctx, query, newEmail, tokenHash, expiresAt, id).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		logging.FromContext(ctx).Error("Error requesting email change", "err", err)
+		return nil, err
 	}
+	return &user, nil
+}
 
-	query := `UPDATE users SET name = $1, email = $2, role = $3 WHERE id = $4 returning id`
+// ConfirmEmailChange makes the pending email of the user holding the token
+// their email, and returns the user and their previous email. The email
+// identity moves to the new address and every session of the user is
+// revoked, so the old address and stolen sessions no longer sign in.
+func ConfirmEmailChange(ctx context.Context, tokenHash string) (*models.User, string, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, "", errors.New("database connection is not initialized")
+	}
 
-	err := database.DB.QueryRow(ctx, query, user.Name, user.Email, user.Role, user.ID).Scan(&user.ID)
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE users u SET email = u.pending_email,
+				  pending_email = NULL, email_change_token_hash = NULL, email_change_expires_at = NULL
+			  FROM (SELECT id, email FROM users
+					WHERE email_change_token_hash = $1 AND email_change_expires_at > NOW() FOR UPDATE) old
+			  WHERE u.id = old.id
+			  RETURNING u.id, u.name, u.email, u.role, u.version, u.updated_at, old.email`
+
+	var user models.User
+	var previous string
+	err = tx.QueryRow(ctx, query, tokenHash).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt, &previous)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return errors.New("user not found")
+			return nil, "", ErrInvalidEmailChangeToken
 		}
-		log.Printf("Error updating user: %v", err)
+		if isUniqueViolation(err) {
+			return nil, "", ErrEmailTaken
+		}
+		logging.FromContext(ctx).Error("Error confirming email change", "err", err)
+		return nil, "", err
+	}
+
+	if err := moveEmail(ctx, tx, user.ID, previous, user.Email); err != nil {
+		return nil, "", err
+	}
+	return &user, previous, tx.Commit(ctx)
+}
+
+// moveEmail follows a change of users.email made in tx: the user's email
+// sign-in identity moves to the new address and their sessions, issued
+// for the old one, are revoked
+func moveEmail(ctx context.Context, tx pgx.Tx, userID int, previous, email string) error {
+	query := `UPDATE user_identities SET subject = LOWER($1), email = $1
+			  WHERE user_id = $2 AND provider = $3 AND subject = LOWER($4)`
+	if _, err := tx.Exec(ctx, query, email, userID, models.ProviderEmail, previous); err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		logging.FromContext(ctx).Error("Error moving email identity", "err", err)
+		return err
+	}
+
+	query = `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()`
+	if _, err := tx.Exec(ctx, query, userID); err != nil {
+		logging.FromContext(ctx).Error("Error revoking sessions", "err", err)
 		return err
 	}
 	return nil
 }
 
-// DeleteUser removes a user from the database
-func DeleteUser(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// DeleteUser removes a user from the database and records the deletion,
+// made by actorID through source, in the audit log. Deleting the last active
+// administrator fails with ErrLastAdmin.
+func DeleteUser(ctx context.Context, id int, actorID *int, source string) error {
+	ctx, cancel := withTimeout(ctx, opWrite)
 	defer cancel()
 
 	if database.DB == nil {
 		return errors.New("database connection is not initialized")
 	}
 
-	query := `DELETE FROM users WHERE id = $1`
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
 
-	result, err := database.DB.Exec(ctx, query, id)
+	last, err := isLastAdmin(ctx, tx, id)
 	if err != nil {
-		log.Printf("Error deleting user: %v", err)
 		return err
 	}
+	if last {
+		return ErrLastAdmin
+	}
 
-	if result.RowsAffected() == 0 {
-		return errors.New("user not found")
+	var email, role string
+	err = tx.QueryRow(ctx, `SELECT email, role FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&email, &role)
+	if err == pgx.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("Error deleting user", "err", err)
+		return err
 	}
 
-	return nil
+	// Recorded first: the entry's subject is cleared when the user goes,
+	// so the metadata identifies them
+	err = insertAudit(ctx, tx, &models.AuditEntry{
+		ActorID:   actorID,
+		SubjectID: &id,
+		Action:    models.AuditUserDeleted,
+		Metadata:  map[string]interface{}{"user_id": id, "email": email, "role": role, "source": source},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, id); err != nil {
+		logging.FromContext(ctx).Error("Error deleting user", "err", err)
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 // GetUserByEmail fetches a user by their email
-func GetUserByEmail(email string) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
 	defer cancel()
 
 	if database.DB == nil {
 		return nil, errors.New("database connection is not initialized")
 	}
 
-	query := `SELECT id, name, email, role FROM users WHERE email = $1`
+	query := `SELECT id, name, email, role, version, updated_at FROM users WHERE email = $1`
 
 	var user models.User
-	err := database.DB.QueryRow(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Role)
+	err := database.DB.QueryRow(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, errors.New("user not found")
 		}
-		log.Printf("Error fetching user by email: %v", err)
+		logging.FromContext(ctx).Error("Error fetching user by email", "err", err)
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUsersByIDs fetches the users with the given IDs in one query, keyed by
+// ID. IDs with no user are absent from the map.
+func GetUsersByIDs(ctx context.Context, ids []int) (map[int]*models.User, error) {
+	users, err := getUsersWhere(ctx, `id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	return byID, nil
+}
+
+// GetUsersByEmails fetches the users with the given emails in one query,
+// keyed by email. Emails with no user are absent from the map.
+func GetUsersByEmails(ctx context.Context, emails []string) (map[string]*models.User, error) {
+	users, err := getUsersWhere(ctx, `email = ANY($1)`, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	byEmail := make(map[string]*models.User, len(users))
+	for _, user := range users {
+		byEmail[user.Email] = user
+	}
+	return byEmail, nil
+}
+
+func getUsersWhere(ctx context.Context, condition string, keys interface{}) ([]*models.User, error) {
+	ctx, cancel := withTimeout(ctx, opRead)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	query := `SELECT id, name, email, role, version, updated_at FROM users WHERE ` + condition
+
+	var users []*models.User
+	err := database.Read(ctx, func(db *pgxpool.Pool) error {
+		rows, err := db.Query(ctx, query, keys)
+		if err != nil {
+			return err
+		}
+		users, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.User, error) {
+			var user models.User
+			err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Version, &user.UpdatedAt)
+			return &user, err
+		})
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error batch fetching users", "err", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// ErrAdminExists is returned by BootstrapAdmin when an administrator already exists
+var ErrAdminExists = errors.New("an administrator already exists")
+
+// ErrLastAdmin is returned by SetUserRole, imports and group changes when
+// the change would leave no active administrator
+var ErrLastAdmin = errors.New("cannot remove the ADMIN role from the last administrator")
+
+// adminsLock serialises changes that depend on how many administrators exist
+const adminsLock = "bootstrap-admin"
+
+// SetUserRole changes a user's role and returns the previous one. Taking
+// ADMIN away fails with ErrLastAdmin unless another active user, or this
+// one through a group, remains an administrator.
+func SetUserRole(ctx context.Context, id int, role string) (string, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return "", errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	// Two admins demoting each other at once must not both succeed
+	if role != models.RoleAdmin {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, adminsLock); err != nil {
+			return "", err
+		}
+	}
+
+	query := `UPDATE users u SET role = $1 FROM (SELECT id, role FROM users WHERE id = $2 FOR UPDATE) old
+			  WHERE u.id = old.id RETURNING old.role`
+
+	var previous string
+	if err := tx.QueryRow(ctx, query, role, id).Scan(&previous); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", ErrUserNotFound
+		}
+		logging.FromContext(ctx).Error("Error setting user role", "err", err)
+		return "", err
+	}
+
+	if previous == models.RoleAdmin && role != models.RoleAdmin {
+		exists, err := adminExists(ctx, tx, 0)
+		if err != nil {
+			logging.FromContext(ctx).Error("Error counting administrators", "err", err)
+			return "", err
+		}
+		if !exists {
+			return "", ErrLastAdmin
+		}
+	}
+	return previous, tx.Commit(ctx)
+}
+
+// adminExists reports whether any active user other than except holds
+// ADMIN, directly or through a group that grants it or one nested inside
+// such a group. An except of 0 counts every user.
+func adminExists(ctx context.Context, tx pgx.Tx, except int) (bool, error) {
+	query := `WITH RECURSIVE admin_groups(id) AS (
+				  SELECT group_id FROM group_roles WHERE role = $1
+				  UNION
+				  SELECT s.child_id FROM group_subgroups s JOIN admin_groups a ON s.parent_id = a.id
+			  )
+			  SELECT EXISTS (
+				  SELECT 1 FROM users u WHERE u.active AND u.id <> $2 AND (u.role = $1 OR EXISTS (
+					  SELECT 1 FROM group_members m JOIN admin_groups a ON a.id = m.group_id WHERE m.user_id = u.id
+				  ))
+			  )`
+
+	var exists bool
+	err := tx.QueryRow(ctx, query, models.RoleAdmin, except).Scan(&exists)
+	return exists, err
+}
+
+// isLastAdmin reports whether id is the only active administrator. It
+// takes the administrators lock, so the answer holds until tx ends.
+func isLastAdmin(ctx context.Context, tx pgx.Tx, id int) (bool, error) {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, adminsLock); err != nil {
+		return false, err
+	}
+
+	exists, err := adminExists(ctx, tx, 0)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error counting administrators", "err", err)
+		return false, err
+	}
+	others, err := adminExists(ctx, tx, id)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error counting administrators", "err", err)
+		return false, err
+	}
+	return exists && !others, nil
+}
+
+// BootstrapAdmin makes the user with the given email the first administrator,
+// creating the account if needed. It fails with ErrAdminExists once any
+// administrator exists, so it cannot be used to take over a running system.
+func BootstrapAdmin(ctx context.Context, name, email string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, opWrite)
+	defer cancel()
+
+	if database.DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize concurrent bootstraps so only one can observe "no admins"
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, adminsLock); err != nil {
+		return nil, err
+	}
+
+	var admins int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM users WHERE role = $1`, models.RoleAdmin).Scan(&admins); err != nil {
+		return nil, err
+	}
+	if admins > 0 {
+		return nil, ErrAdminExists
+	}
+
+	user := models.User{Name: name, Email: email, Role: models.RoleAdmin}
+	err = tx.QueryRow(ctx, `UPDATE users SET role = $1 WHERE email = $2 RETURNING id, name`, models.RoleAdmin, email).
+		Scan(&user.ID, &user.Name)
+	if err == pgx.ErrNoRows {
+		err = tx.QueryRow(ctx, `INSERT INTO users (name, email, role) VALUES ($1, $2, $3) RETURNING id`, name, email, models.RoleAdmin).
+			Scan(&user.ID)
+		if err == nil {
+			err = insertIdentity(ctx, tx, &models.Identity{
+				UserID: user.ID, Provider: models.ProviderEmail, Subject: strings.ToLower(email), Email: email,
+			})
+		}
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("Error bootstrapping admin", "err", err)
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO audit_log (subject_user_id, action, metadata) VALUES ($1, $2, $3)`,
+		user.ID, models.AuditBootstrapAdmin, map[string]interface{}{"source": "umsctl"})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-service/internal/database"
+	"user-management-service/internal/models"
+)
+
+func TestConfirmEmailChange(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	if err := database.ConnectDB(context.Background(), databaseURL, database.DefaultPoolSettings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseDB)
+
+	ctx := context.Background()
+	if _, err := database.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	suffix := time.Now().UnixNano()
+	oldEmail := fmt.Sprintf("Old-%d@example.com", suffix)
+	newEmail := fmt.Sprintf("new-%d@example.com", suffix)
+	user := &models.User{Name: "Mover", Email: oldEmail, Role: models.RoleUser}
+	identity := &models.Identity{Provider: models.ProviderEmail, Subject: strings.ToLower(oldEmail), Email: oldEmail}
+	if err := CreateUserWithIdentity(ctx, user, identity); err != nil {
+		t.Fatalf("CreateUserWithIdentity: %v", err)
+	}
+	t.Cleanup(func() { DeleteUser(context.Background(), user.ID, nil, "test") })
+
+	session := &models.Session{ID: fmt.Sprintf("email-change-%d", suffix), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	expiredHash := fmt.Sprintf("expired-%d", suffix)
+	if _, err := RequestEmailChange(ctx, user.ID, newEmail, expiredHash, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("RequestEmailChange: %v", err)
+	}
+	if _, _, err := ConfirmEmailChange(ctx, expiredHash); !errors.Is(err, ErrInvalidEmailChangeToken) {
+		t.Fatalf("expected an expired token to be rejected, got %v", err)
+	}
+
+	tokenHash := fmt.Sprintf("hash-%d", suffix)
+	if _, err := RequestEmailChange(ctx, user.ID, newEmail, tokenHash, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RequestEmailChange: %v", err)
+	}
+	changed, previous, err := ConfirmEmailChange(ctx, tokenHash)
+	if err != nil {
+		t.Fatalf("ConfirmEmailChange: %v", err)
+	}
+	if changed.Email != newEmail || previous != oldEmail {
+		t.Errorf("email changed from %q to %q, want %q to %q", previous, changed.Email, oldEmail, newEmail)
+	}
+	if _, _, err := ConfirmEmailChange(ctx, tokenHash); !errors.Is(err, ErrInvalidEmailChangeToken) {
+		t.Errorf("expected a used token to be rejected, got %v", err)
+	}
+
+	if linked, err := GetIdentity(ctx, models.ProviderEmail, strings.ToLower(oldEmail)); err != nil || linked != nil {
+		t.Errorf("expected the old address to no longer sign in, got %+v (%v)", linked, err)
+	}
+	if linked, err := GetIdentity(ctx, models.ProviderEmail, newEmail); err != nil || linked == nil || linked.UserID != user.ID {
+		t.Errorf("expected the new address to sign in as the user, got %+v (%v)", linked, err)
+	}
+	if got, err := GetSession(ctx, session.ID); err != nil || got == nil || got.RevokedAt == nil {
+		t.Errorf("expected the session to be revoked, got %+v (%v)", got, err)
+	}
+}
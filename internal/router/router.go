@@ -1,10 +1,6 @@
 package router
 
 import (
-
-	// pprof for profiling
-	"net/http/pprof"
-
 	"user-management-service/internal/handlers"
 
 	"github.com/gorilla/mux"
@@ -15,21 +11,22 @@ func SetupRouter() *mux.Router {
 
 	r.HandleFunc("/health", handlers.HealthCheck).Methods("GET")
 	r.HandleFunc("/users", handlers.CreateUser).Methods("POST")
-	r.HandleFunc("/users", handlers.CreateUser).Methods("POST")
+	// Bulk and search routes come first so "import", "export" and "search"
+	// are not taken for IDs
+	r.HandleFunc("/users/import", handlers.ImportUsers).Methods("POST")
+	r.HandleFunc("/users/import/{id}", handlers.GetImportJob).Methods("GET")
+	r.HandleFunc("/users/export", handlers.ExportUsers).Methods("GET")
+	r.HandleFunc("/users/search", handlers.SearchUsers).Methods("GET")
 	r.HandleFunc("/users/{id}", handlers.GetUser).Methods("GET")
+	r.HandleFunc("/users/{id}/role", handlers.SetUserRole).Methods("PATCH")
 	r.HandleFunc("/users/{id}", handlers.UpdateUser).Methods("PUT")
+	r.HandleFunc("/users/{id}", handlers.PatchUser).Methods("PATCH")
 	r.HandleFunc("/users/{id}", handlers.DeleteUser).Methods("DELETE")
+	r.HandleFunc("/me/export", handlers.ExportMyData).Methods("GET")
 
 	// Auth Routes
 	r.HandleFunc("/auth/login", handlers.RequestOTP).Methods("POST")
 	r.HandleFunc("/auth/verify", handlers.VerifyOTP).Methods("POST")
 
-	// Pprof handlers
-	r.HandleFunc("/debug/pprof/", pprof.Index)
-	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
-
 	return r
 }
@@ -0,0 +1,108 @@
+package scim
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// schemasJSON describes the User and Group resources as served by /Schemas
+//
+//go:embed schemas.json
+var schemasJSON []byte
+
+var schemas []map[string]interface{}
+
+func init() {
+	if err := json.Unmarshal(schemasJSON, &schemas); err != nil {
+		panic("scim: invalid schemas.json: " + err.Error())
+	}
+}
+
+// ServiceProviderConfig handles GET /ServiceProviderConfig
+func ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"schemas":          []string{SchemaServiceProviderConfig},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            map[string]bool{"supported": true},
+		"bulk":             map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]interface{}{"supported": true, "maxResults": maxCount},
+		"changePassword":   map[string]bool{"supported": false},
+		"sort":             map[string]bool{"supported": false},
+		"etag":             map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer API key",
+			"description": "An API key with the scim scope, owned by an admin, sent as Authorization: Bearer <key>",
+			"primary":     true,
+		}},
+		"meta": meta{ResourceType: "ServiceProviderConfig", Location: location(r, "/ServiceProviderConfig")},
+	})
+}
+
+// resourceTypes lists the provisionable resources
+func resourceTypes(r *http.Request) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"schemas":     []string{SchemaResourceType},
+			"id":          "User",
+			"name":        "User",
+			"endpoint":    "/Users",
+			"description": "User account",
+			"schema":      SchemaUser,
+			"meta":        meta{ResourceType: "ResourceType", Location: location(r, "/ResourceTypes/User")},
+		},
+		{
+			"schemas":     []string{SchemaResourceType},
+			"id":          "Group",
+			"name":        "Group",
+			"endpoint":    "/Groups",
+			"description": "Role",
+			"schema":      SchemaGroup,
+			"meta":        meta{ResourceType: "ResourceType", Location: location(r, "/ResourceTypes/Group")},
+		},
+	}
+}
+
+// ResourceTypes handles GET /ResourceTypes
+func ResourceTypes(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, resourceTypes(r))
+}
+
+// ResourceType handles GET /ResourceTypes/{id}
+func ResourceType(w http.ResponseWriter, r *http.Request) {
+	writeByID(w, r, resourceTypes(r))
+}
+
+// Schemas handles GET /Schemas
+func Schemas(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, schemas)
+}
+
+// Schema handles GET /Schemas/{id}
+func Schema(w http.ResponseWriter, r *http.Request) {
+	writeByID(w, r, schemas)
+}
+
+func writeList(w http.ResponseWriter, r *http.Request, resources []map[string]interface{}) {
+	writeJSON(w, r, http.StatusOK, listResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: len(resources),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func writeByID(w http.ResponseWriter, r *http.Request, resources []map[string]interface{}) {
+	id := mux.Vars(r)["id"]
+	for _, resource := range resources {
+		if resource["id"] == id {
+			writeJSON(w, r, http.StatusOK, resource)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "", "resource not found")
+}
@@ -0,0 +1,100 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// condition is one "attribute eq value" comparison of a filter
+type condition struct {
+	attr  string
+	value interface{}
+}
+
+// parseFilter parses the subset of RFC 7644 filters that provisioning
+// clients send to look resources up: equality comparisons, optionally
+// joined with "and", such as `userName eq "jane@example.com"`. Attribute
+// names are returned lower-cased, since they are case-insensitive.
+func parseFilter(filter string) ([]condition, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var conditions []condition
+	for {
+		if len(tokens) < 3 {
+			return nil, fmt.Errorf("incomplete filter %q", filter)
+		}
+		attr, op, value := tokens[0], tokens[1], tokens[2]
+		if attr.quoted || op.quoted || !strings.EqualFold(op.text, "eq") {
+			return nil, fmt.Errorf("unsupported filter %q: only eq comparisons are supported", filter)
+		}
+
+		c := condition{attr: strings.ToLower(attr.text)}
+		switch {
+		case value.quoted:
+			c.value = value.text
+		case value.text == "true", value.text == "false":
+			c.value = value.text == "true"
+		default:
+			return nil, fmt.Errorf("unsupported value %s in filter", value.text)
+		}
+		conditions = append(conditions, c)
+
+		tokens = tokens[3:]
+		if len(tokens) == 0 {
+			return conditions, nil
+		}
+		if tokens[0].quoted || !strings.EqualFold(tokens[0].text, "and") {
+			return nil, fmt.Errorf("unsupported filter %q: only and is supported", filter)
+		}
+		tokens = tokens[1:]
+	}
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits a filter into words and JSON string literals
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string in filter %q", s)
+			}
+			var text string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &text); err != nil {
+				return nil, fmt.Errorf("invalid string in filter %q", s)
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = end + 1
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			return nil, fmt.Errorf("unsupported filter %q: grouping is not supported", s)
+		default:
+			end := i
+			for end < len(s) && s[end] != ' ' && s[end] != '\t' && s[end] != '"' {
+				end++
+			}
+			tokens = append(tokens, token{text: s[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,384 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// roles are the groups, in the order they are listed
+var roles = []string{models.RoleAdmin, models.RoleSupport, models.RoleUser}
+
+// groupResource is the SCIM representation of a role
+type groupResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	DisplayName string   `json:"displayName"`
+	Members     []member `json:"members,omitempty"`
+	Meta        *meta    `json:"meta,omitempty"`
+}
+
+type member struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// toGroupResource describes a role, listing its members unless the client
+// excluded them; clients usually do, as the USER group holds most users
+func toGroupResource(r *http.Request, role string) (*groupResource, error) {
+	group := &groupResource{
+		Schemas:     []string{SchemaGroup},
+		ID:          role,
+		DisplayName: role,
+		Meta:        &meta{ResourceType: "Group", Location: location(r, "/Groups/"+role)},
+	}
+	if excluded(r, "members") {
+		return group, nil
+	}
+
+	group.Members = []member{}
+	err := repository.StreamUsers(r.Context(), repository.UserFilter{Role: role}, func(user *models.User) error {
+		id := strconv.Itoa(user.ID)
+		group.Members = append(group.Members, member{Value: id, Display: user.Email, Ref: location(r, "/Users/"+id)})
+		return nil
+	})
+	return group, err
+}
+
+// groupRoles translates a SCIM filter into the roles it selects
+func groupRoles(filter string) ([]string, error) {
+	conditions, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []string
+	for _, role := range roles {
+		match := true
+		for _, c := range conditions {
+			if c.attr != "displayname" && c.attr != "id" {
+				return nil, fmt.Errorf("filtering on %s is not supported", c.attr)
+			}
+			if s, ok := c.value.(string); !ok || !strings.EqualFold(s, role) {
+				match = false
+			}
+		}
+		if match {
+			selected = append(selected, role)
+		}
+	}
+	return selected, nil
+}
+
+// lookupGroup finds the role named by the route, writing a 404 if there is none
+func lookupGroup(w http.ResponseWriter, r *http.Request) string {
+	role := strings.ToUpper(mux.Vars(r)["id"])
+	if !models.IsValidRole(role) {
+		writeError(w, http.StatusNotFound, "", "group not found")
+		return ""
+	}
+	return role
+}
+
+// ListGroups handles GET /Groups
+func ListGroups(w http.ResponseWriter, r *http.Request) {
+	selected, err := groupRoles(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := page(r)
+
+	resources := []*groupResource{}
+	for i := startIndex - 1; i < len(selected) && len(resources) < count; i++ {
+		group, err := toGroupResource(r, selected[i])
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "", "failed to list group members")
+			return
+		}
+		resources = append(resources, group)
+	}
+	writeJSON(w, r, http.StatusOK, listResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: len(selected),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// GetGroup handles GET /Groups/{id}
+func GetGroup(w http.ResponseWriter, r *http.Request) {
+	role := lookupGroup(w, r)
+	if role == "" {
+		return
+	}
+	writeGroup(w, r, role)
+}
+
+func writeGroup(w http.ResponseWriter, r *http.Request, role string) {
+	group, err := toGroupResource(r, role)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to list group members")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, group)
+}
+
+// readOnlyGroups answers requests to create or delete groups, which are
+// the fixed roles
+func readOnlyGroups(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusForbidden, "", "groups are the fixed roles ADMIN, SUPPORT and USER and cannot be created or deleted")
+}
+
+// ReplaceGroup handles PUT /Groups/{id}: the listed users get the role and
+// members not listed are moved back to USER
+func ReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	role := lookupGroup(w, r)
+	if role == "" {
+		return
+	}
+
+	var res groupResource
+	if !decode(w, r, &res) {
+		return
+	}
+	if res.DisplayName != "" && !strings.EqualFold(res.DisplayName, role) {
+		writeError(w, http.StatusBadRequest, "mutability", "groups cannot be renamed")
+		return
+	}
+
+	if err := replaceMembers(r, role, res.Members); err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+	writeGroup(w, r, role)
+}
+
+// PatchGroup handles PATCH /Groups/{id}, adding and removing members
+func PatchGroup(w http.ResponseWriter, r *http.Request) {
+	role := lookupGroup(w, r)
+	if role == "" {
+		return
+	}
+
+	var patch patchRequest
+	if !decode(w, r, &patch) {
+		return
+	}
+
+	for _, operation := range patch.Operations {
+		op, err := operation.op()
+		if err != nil {
+			writeMembershipError(w, err)
+			return
+		}
+		if err := applyGroupOperation(r, role, op, operation); err != nil {
+			writeMembershipError(w, err)
+			return
+		}
+	}
+
+	// Echoing every member back would be expensive for large groups
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func applyGroupOperation(r *http.Request, role, op string, operation patchOperation) error {
+	rawPath := strings.TrimSpace(operation.Path)
+	path := strings.ToLower(rawPath)
+
+	// Azure AD sends remove with a path filter, others a value list
+	if op == "remove" && strings.HasPrefix(path, "members[") && strings.HasSuffix(path, "]") {
+		id, err := memberFilter(rawPath[len("members[") : len(rawPath)-1])
+		if err != nil {
+			return err
+		}
+		return setRoles(r, []string{id}, models.RoleUser, role)
+	}
+
+	switch path {
+	case "members":
+	case "", "displayname":
+		return applyGroupAttributes(r, role, op, path, operation.Value)
+	default:
+		return invalidValue("unsupported path %q", operation.Path)
+	}
+
+	members, err := parseMembers(operation.Value, op == "remove")
+	if err != nil {
+		return err
+	}
+	switch op {
+	case "add":
+		return setRoles(r, members, role, "")
+	case "remove":
+		if len(members) == 0 {
+			return invalidValue("remove members by value; removing every member at once is not supported")
+		}
+		return setRoles(r, members, models.RoleUser, role)
+	default:
+		return replaceMembers(r, role, memberList(members))
+	}
+}
+
+// applyGroupAttributes handles operations without a members path, which
+// may only set members or repeat the group's name
+func applyGroupAttributes(r *http.Request, role, op, path string, value json.RawMessage) error {
+	var values map[string]json.RawMessage
+	if path == "" {
+		if err := json.Unmarshal(value, &values); err != nil {
+			return invalidValue("an operation without a path needs an object value")
+		}
+	} else {
+		values = map[string]json.RawMessage{path: value}
+	}
+
+	for key, v := range values {
+		switch strings.ToLower(key) {
+		case "displayname":
+			var name string
+			if json.Unmarshal(v, &name) != nil || !strings.EqualFold(name, role) {
+				return &patchError{scimType: "mutability", detail: "groups cannot be renamed"}
+			}
+		case "members":
+			if err := applyGroupOperation(r, role, op, patchOperation{Path: "members", Value: v}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// memberFilter reads the user ID from a `value eq "42"` path filter
+func memberFilter(filter string) (string, error) {
+	conditions, err := parseFilter(filter)
+	if err != nil {
+		return "", invalidValue("%v", err)
+	}
+	if len(conditions) == 1 && conditions[0].attr == "value" {
+		if id, ok := conditions[0].value.(string); ok {
+			return id, nil
+		}
+	}
+	return "", invalidValue("members can only be selected by value")
+}
+
+// parseMembers reads a list of member references. A missing value is only
+// allowed for remove, where it means every member.
+func parseMembers(value json.RawMessage, optional bool) ([]string, error) {
+	if len(value) == 0 || string(value) == "null" {
+		if optional {
+			return nil, nil
+		}
+		return nil, invalidValue("members value is required")
+	}
+
+	var members []member
+	if err := json.Unmarshal(value, &members); err != nil {
+		return nil, invalidValue("members must be a list of {\"value\": \"<user id>\"}")
+	}
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = m.Value
+	}
+	return ids, nil
+}
+
+func memberList(ids []string) []member {
+	members := make([]member, len(ids))
+	for i, id := range ids {
+		members[i] = member{Value: id}
+	}
+	return members
+}
+
+// replaceMembers makes members exactly the users with role, moving anyone
+// else with the role back to USER. USER cannot be emptied that way.
+func replaceMembers(r *http.Request, role string, members []member) error {
+	keep := make(map[string]bool, len(members))
+	ids := make([]string, len(members))
+	for i, m := range members {
+		keep[m.Value] = true
+		ids[i] = m.Value
+	}
+
+	if err := setRoles(r, ids, role, ""); err != nil {
+		return err
+	}
+	if role == models.RoleUser {
+		return nil
+	}
+
+	var removed []string
+	err := repository.StreamUsers(r.Context(), repository.UserFilter{Role: role}, func(user *models.User) error {
+		if id := strconv.Itoa(user.ID); !keep[id] {
+			removed = append(removed, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return setRoles(r, removed, models.RoleUser, role)
+}
+
+// setRoles gives the users role. When only is set, users are left alone
+// unless they currently have that role, so removing someone from a group
+// they are not in does nothing.
+func setRoles(r *http.Request, ids []string, role, only string) error {
+	for _, value := range ids {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return invalidValue("unknown member %q", value)
+		}
+		user, err := repository.GetDirectoryUser(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return invalidValue("unknown member %q", value)
+		}
+		if user.Role == role || (only != "" && user.Role != only) {
+			continue
+		}
+
+		previous, err := repository.SetUserRole(r.Context(), id, role)
+		if err != nil {
+			return err
+		}
+		err = repository.RecordAudit(r.Context(), &models.AuditEntry{
+			ActorID:   actorID(r),
+			SubjectID: &id,
+			Action:    models.AuditRoleChanged,
+			Metadata:  map[string]interface{}{"from": previous, "to": role, "source": "scim"},
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to record role change", "user_id", id, "err", err)
+		}
+	}
+	return nil
+}
+
+// writeMembershipError reports a failed membership change
+func writeMembershipError(w http.ResponseWriter, err error) {
+	var pe *patchError
+	if errors.As(err, &pe) {
+		writeError(w, http.StatusBadRequest, pe.scimType, pe.detail)
+		return
+	}
+	if errors.Is(err, repository.ErrLastAdmin) {
+		writeError(w, http.StatusConflict, "", err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "", "failed to update group members")
+}
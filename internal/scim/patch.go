@@ -0,0 +1,180 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"user-management-service/internal/repository"
+)
+
+// patchRequest is a PATCH body (RFC 7644 section 3.5.2)
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// patchError carries the scimType to report for a rejected patch
+type patchError struct {
+	scimType string
+	detail   string
+}
+
+func (e *patchError) Error() string { return e.detail }
+
+func invalidValue(format string, args ...interface{}) error {
+	return &patchError{scimType: "invalidValue", detail: fmt.Sprintf(format, args...)}
+}
+
+// patchErrorType is the scimType for an error from applying a patch
+func patchErrorType(err error) string {
+	var pe *patchError
+	if errors.As(err, &pe) {
+		return pe.scimType
+	}
+	return "invalidValue"
+}
+
+// op is the normalised operation name. Azure AD capitalises them.
+func (o patchOperation) op() (string, error) {
+	switch op := strings.ToLower(o.Op); op {
+	case "add", "replace", "remove":
+		return op, nil
+	}
+	return "", &patchError{scimType: "invalidSyntax", detail: fmt.Sprintf("unknown op %q", o.Op)}
+}
+
+// attrName normalises an attribute path: lower case, without the core
+// schema URN some clients prefix it with
+func attrName(path string) string {
+	path = strings.ToLower(strings.TrimSpace(path))
+	return strings.TrimPrefix(path, strings.ToLower(SchemaUser)+":")
+}
+
+// applyToUser applies the operations to user in order. Attributes this
+// service does not store, including emails, which follow userName, are
+// ignored so that identity providers can send their full attribute mapping.
+func (p *patchRequest) applyToUser(user *repository.DirectoryUser) error {
+	given, family, _ := strings.Cut(user.Name, " ")
+	nameParts := false
+
+	set := func(attr string, value json.RawMessage) error {
+		switch attr {
+		case "active":
+			active, err := parseBool(value)
+			if err != nil {
+				return err
+			}
+			user.Active = active
+		case "username":
+			s, err := parseString(attr, value)
+			if err != nil {
+				return err
+			}
+			user.Email = strings.TrimSpace(s)
+		case "displayname", "name.formatted":
+			s, err := parseString(attr, value)
+			if err != nil {
+				return err
+			}
+			user.Name = strings.TrimSpace(s)
+			given, family, _ = strings.Cut(user.Name, " ")
+		case "name":
+			var n userName
+			if err := json.Unmarshal(value, &n); err != nil {
+				return invalidValue("name must be an object")
+			}
+			if n.Formatted == "" {
+				given, family, nameParts = n.GivenName, n.FamilyName, true
+			} else {
+				user.Name = n.full()
+				given, family, _ = strings.Cut(user.Name, " ")
+			}
+		case "name.givenname":
+			s, err := parseString(attr, value)
+			if err != nil {
+				return err
+			}
+			given, nameParts = s, true
+		case "name.familyname":
+			s, err := parseString(attr, value)
+			if err != nil {
+				return err
+			}
+			family, nameParts = s, true
+		}
+		return nil
+	}
+
+	for _, operation := range p.Operations {
+		op, err := operation.op()
+		if err != nil {
+			return err
+		}
+		attr := attrName(operation.Path)
+
+		switch {
+		case op == "remove":
+			if attr == "active" || attr == "username" {
+				return invalidValue("%s is required and cannot be removed", operation.Path)
+			}
+		case attr == "":
+			// No path: the value holds the attributes to set
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return invalidValue("an operation without a path needs an object value")
+			}
+			for key, value := range values {
+				if err := set(attrName(key), value); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := set(attr, operation.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if nameParts {
+		user.Name = strings.TrimSpace(strings.TrimSpace(given) + " " + strings.TrimSpace(family))
+	}
+	if user.Name == "" {
+		return invalidValue("name cannot be empty")
+	}
+	return nil
+}
+
+func parseString(attr string, value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", invalidValue("%s must be a string", attr)
+	}
+	return s, nil
+}
+
+// parseBool accepts JSON booleans and, as Azure AD sends them, the strings
+// "True" and "False"
+func parseBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, invalidValue("active must be true or false")
+}
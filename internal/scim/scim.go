@@ -0,0 +1,185 @@
+// Package scim lets identity providers such as Okta and Azure AD provision
+// users with SCIM 2.0 (RFC 7643, RFC 7644). Users map onto the users table,
+// with userName as the email address; groups are the fixed roles, so
+// adding a user to a group sets their role.
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"user-management-service/internal/logging"
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Schema URNs
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// Prefix is where the SCIM endpoints are mounted
+const Prefix = "/scim/v2"
+
+// Page sizes for list requests
+const (
+	defaultCount = 100
+	maxCount     = 200
+)
+
+// maxBodySize bounds request bodies, which are single resources or patches
+const maxBodySize = 1 << 20
+
+// RegisterRoutes mounts the SCIM endpoints on the router. They accept API
+// keys of admins with the scim scope as bearer tokens.
+func RegisterRoutes(r *mux.Router) {
+	s := r.PathPrefix(Prefix).Subrouter()
+	s.Use(requireProvisioner)
+
+	s.HandleFunc("/ServiceProviderConfig", ServiceProviderConfig).Methods("GET")
+	s.HandleFunc("/ResourceTypes", ResourceTypes).Methods("GET")
+	s.HandleFunc("/ResourceTypes/{id}", ResourceType).Methods("GET")
+	s.HandleFunc("/Schemas", Schemas).Methods("GET")
+	s.HandleFunc("/Schemas/{id}", Schema).Methods("GET")
+
+	s.HandleFunc("/Users", ListUsers).Methods("GET")
+	s.HandleFunc("/Users", CreateUser).Methods("POST")
+	s.HandleFunc("/Users/{id}", GetUser).Methods("GET")
+	s.HandleFunc("/Users/{id}", ReplaceUser).Methods("PUT")
+	s.HandleFunc("/Users/{id}", PatchUser).Methods("PATCH")
+	s.HandleFunc("/Users/{id}", DeleteUser).Methods("DELETE")
+
+	s.HandleFunc("/Groups", ListGroups).Methods("GET")
+	s.HandleFunc("/Groups", readOnlyGroups).Methods("POST")
+	s.HandleFunc("/Groups/{id}", GetGroup).Methods("GET")
+	s.HandleFunc("/Groups/{id}", ReplaceGroup).Methods("PUT")
+	s.HandleFunc("/Groups/{id}", PatchGroup).Methods("PATCH")
+	s.HandleFunc("/Groups/{id}", readOnlyGroups).Methods("DELETE")
+}
+
+// requireProvisioner only lets through admins whose credential carries the
+// scim scope, and not while impersonating
+func requireProvisioner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userinfo := middleware.ForContext(r.Context())
+		if userinfo == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			writeError(w, http.StatusUnauthorized, "", "a bearer token is required")
+			return
+		}
+		if !userinfo.HasRole(models.RoleAdmin) || !userinfo.HasScope(models.ScopeSCIM) || userinfo.IsImpersonated() {
+			writeError(w, http.StatusForbidden, "", "the token is not allowed to provision users")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// actorID is the provisioning credential's owner, for the audit log
+func actorID(r *http.Request) *int {
+	userinfo := middleware.ForContext(r.Context())
+	if userinfo == nil {
+		return nil
+	}
+	id, err := strconv.Atoi(userinfo.ID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// meta is the common resource metadata
+type meta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+// listResponse is a page of resources
+type listResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// page reads the 1-based startIndex and count parameters
+func page(r *http.Request) (startIndex, count int) {
+	startIndex, count = 1, defaultCount
+	if n, err := strconv.Atoi(r.URL.Query().Get("startIndex")); err == nil && n > 1 {
+		startIndex = n
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil {
+		count = min(max(n, 0), maxCount)
+	}
+	return startIndex, count
+}
+
+// location is the absolute URL of a resource, as seen by the client
+func location(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + Prefix + path
+}
+
+// decode reads a JSON request body. Unknown attributes, such as those of
+// schema extensions this service does not support, are ignored.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	defer r.Body.Close()
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", "request body is not valid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logging.FromContext(r.Context()).Error("SCIM encode error", "err", err)
+	}
+}
+
+// scimError is the error response body. scimType is only set for 400 and
+// 409 errors, as RFC 7644 section 3.12 defines it.
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func writeError(w http.ResponseWriter, status int, scimType, detail string) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(scimError{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// excluded reports whether the excludedAttributes parameter names attr
+func excluded(r *http.Request, attr string) bool {
+	for _, a := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(a), attr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-management-service/internal/middleware"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func TestParseFilter(t *testing.T) {
+	conditions, err := parseFilter(`userName eq "jane\"o@example.com" and active EQ true`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conditions) != 2 || conditions[0].attr != "username" || conditions[0].value != `jane"o@example.com` || conditions[1].value != true {
+		t.Errorf("unexpected conditions %+v", conditions)
+	}
+
+	for _, filter := range []string{
+		`userName co "jane"`,
+		`userName eq "jane@example.com" or active eq true`,
+		`emails[type eq "work"]`,
+		`userName eq`,
+		`userName eq "unterminated`,
+		`userName eq 42`,
+	} {
+		if _, err := parseFilter(filter); err == nil {
+			t.Errorf("expected %q to be rejected", filter)
+		}
+	}
+}
+
+func TestUserFilter(t *testing.T) {
+	f, err := userFilter(`userName eq "jane@example.com"`)
+	if err != nil || f.Email != "jane@example.com" {
+		t.Errorf("expected an email filter, got %+v (%v)", f, err)
+	}
+	if _, err := userFilter(`title eq "CEO"`); err == nil {
+		t.Error("expected an unsupported attribute to be rejected")
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	user := &repository.DirectoryUser{User: models.User{Name: "Jane Doe", Email: "jane@example.com"}, Active: true}
+
+	// As sent by Azure AD: capitalised ops, string booleans, and
+	// attributes this service does not store
+	var patch patchRequest
+	body := `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [
+		{"op": "Replace", "path": "active", "value": "False"},
+		{"op": "Replace", "path": "name.familyName", "value": "Smith"},
+		{"op": "Add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", "value": "Sales"},
+		{"op": "replace", "value": {"userName": "jane.smith@example.com", "externalId": "abc"}}
+	]}`
+	if err := json.Unmarshal([]byte(body), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if err := patch.applyToUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Active || user.Name != "Jane Smith" || user.Email != "jane.smith@example.com" {
+		t.Errorf("unexpected user after patch %+v", user)
+	}
+
+	patch = patchRequest{Operations: []patchOperation{{Op: "remove", Path: "userName"}}}
+	if err := patch.applyToUser(user); err == nil {
+		t.Error("expected removing userName to be rejected")
+	}
+	patch = patchRequest{Operations: []patchOperation{{Op: "move", Path: "active", Value: json.RawMessage(`true`)}}}
+	if err := patch.applyToUser(user); patchErrorType(err) != "invalidSyntax" {
+		t.Errorf("expected an unknown op to be invalidSyntax, got %v", err)
+	}
+}
+
+func TestMemberFilter(t *testing.T) {
+	id, err := memberFilter(`value eq "42"`)
+	if err != nil || id != "42" {
+		t.Errorf("expected member 42, got %q (%v)", id, err)
+	}
+	if _, err := memberFilter(`display eq "jane"`); err == nil {
+		t.Error("expected a filter on display to be rejected")
+	}
+}
+
+func TestRequireProvisioner(t *testing.T) {
+	r := mux.NewRouter()
+	RegisterRoutes(r)
+
+	tests := []struct {
+		name   string
+		user   *middleware.User
+		status int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"user", &middleware.User{ID: "1", Role: models.RoleUser}, http.StatusForbidden},
+		{"admin key without the scim scope", &middleware.User{ID: "1", Role: models.RoleAdmin, Scopes: []string{models.ScopeRead}}, http.StatusForbidden},
+		{"admin key with the scim scope", &middleware.User{ID: "1", Role: models.RoleAdmin, Scopes: []string{models.ScopeSCIM}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/scim/v2/ServiceProviderConfig", nil)
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), middleware.UserCtxKey, tt.user))
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/scim+json") {
+				t.Errorf("unexpected content type %q", ct)
+			}
+		})
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	r := mux.NewRouter()
+	RegisterRoutes(r)
+
+	req := httptest.NewRequest("GET", "/scim/v2/Schemas/"+SchemaGroup, nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserCtxKey, &middleware.User{ID: "1", Role: models.RoleAdmin}))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var schema struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&schema); err != nil || schema.ID != SchemaGroup {
+		t.Errorf("expected the group schema, got %d %+v (%v)", rec.Code, schema, err)
+	}
+}
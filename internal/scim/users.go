@@ -0,0 +1,311 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"user-management-service/internal/logging"
+	"user-management-service/internal/models"
+	"user-management-service/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// userResource is the SCIM representation of a user. Attributes this
+// service does not store, such as externalId, are accepted and ignored.
+type userResource struct {
+	Schemas     []string   `json:"schemas"`
+	ID          string     `json:"id,omitempty"`
+	UserName    string     `json:"userName"`
+	Name        *userName  `json:"name,omitempty"`
+	DisplayName string     `json:"displayName,omitempty"`
+	Emails      []email    `json:"emails,omitempty"`
+	Active      *bool      `json:"active,omitempty"`
+	Groups      []groupRef `json:"groups,omitempty"`
+	Meta        *meta      `json:"meta,omitempty"`
+}
+
+type userName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type groupRef struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+func toUserResource(r *http.Request, user *repository.DirectoryUser) *userResource {
+	id := strconv.Itoa(user.ID)
+	active := user.Active
+	return &userResource{
+		Schemas:     []string{SchemaUser},
+		ID:          id,
+		UserName:    user.Email,
+		Name:        &userName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []email{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Groups:      []groupRef{{Value: user.Role, Display: user.Role, Ref: location(r, "/Groups/"+user.Role)}},
+		Meta:        &meta{ResourceType: "User", Location: location(r, "/Users/"+id)},
+	}
+}
+
+// apply copies the attributes of res onto user, as for a create or a
+// replace. Attributes left out of res are reset to their defaults.
+func (res *userResource) apply(user *repository.DirectoryUser) error {
+	user.Email = strings.TrimSpace(res.UserName)
+	user.Name = res.Name.full()
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(res.DisplayName)
+	}
+	if user.Name == "" {
+		user.Name = user.Email
+	}
+	user.Active = res.Active == nil || *res.Active
+	return validateUser(user)
+}
+
+// full is the display form of a name
+func (n *userName) full() string {
+	if n == nil {
+		return ""
+	}
+	if n.Formatted != "" {
+		return strings.TrimSpace(n.Formatted)
+	}
+	return strings.TrimSpace(n.GivenName + " " + n.FamilyName)
+}
+
+// validateUser checks a user about to be saved
+func validateUser(user *repository.DirectoryUser) error {
+	if user.Email == "" {
+		return errors.New("userName is required")
+	}
+	if addr, err := mail.ParseAddress(user.Email); err != nil || addr.Address != user.Email {
+		return errors.New("userName must be an email address")
+	}
+	return nil
+}
+
+// userFilter translates a SCIM filter into a repository filter
+func userFilter(filter string) (repository.UserFilter, error) {
+	var f repository.UserFilter
+	conditions, err := parseFilter(filter)
+	if err != nil {
+		return f, err
+	}
+	for _, c := range conditions {
+		switch c.attr {
+		case "username", "emails.value", "emails":
+			s, ok := c.value.(string)
+			if !ok {
+				return f, fmt.Errorf("%s must be compared with a string", c.attr)
+			}
+			if f.Email != "" && f.Email != s {
+				// No user has two email addresses
+				f.Email = "\x00"
+			} else {
+				f.Email = s
+			}
+		case "active":
+			b, ok := c.value.(bool)
+			if !ok {
+				return f, errors.New("active must be compared with true or false")
+			}
+			f.Active = &b
+		default:
+			return f, fmt.Errorf("filtering on %s is not supported", c.attr)
+		}
+	}
+	return f, nil
+}
+
+// lookupUser loads the user named by the route, writing a 404 if there is none
+func lookupUser(w http.ResponseWriter, r *http.Request) *repository.DirectoryUser {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, "", "user not found")
+		return nil
+	}
+	user, err := repository.GetDirectoryUser(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to fetch user")
+		return nil
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "", "user not found")
+		return nil
+	}
+	return user
+}
+
+// ListUsers handles GET /Users, with filter, startIndex and count
+func ListUsers(w http.ResponseWriter, r *http.Request) {
+	filter, err := userFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := page(r)
+
+	users, total, err := repository.ListDirectoryUsers(r.Context(), filter, startIndex-1, count)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "failed to list users")
+		return
+	}
+
+	resources := make([]*userResource, len(users))
+	for i, user := range users {
+		resources[i] = toUserResource(r, user)
+	}
+	writeJSON(w, r, http.StatusOK, listResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// GetUser handles GET /Users/{id}
+func GetUser(w http.ResponseWriter, r *http.Request) {
+	if user := lookupUser(w, r); user != nil {
+		writeJSON(w, r, http.StatusOK, toUserResource(r, user))
+	}
+}
+
+// CreateUser handles POST /Users. New users get the USER role; add them to
+// a group to change it.
+func CreateUser(w http.ResponseWriter, r *http.Request) {
+	var res userResource
+	if !decode(w, r, &res) {
+		return
+	}
+
+	user := &repository.DirectoryUser{User: models.User{Role: models.RoleUser}}
+	if err := res.apply(user); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+
+	if err := repository.CreateDirectoryUser(r.Context(), user); err != nil {
+		if errors.Is(err, repository.ErrEmailTaken) {
+			writeError(w, http.StatusConflict, "uniqueness", "a user with this userName already exists")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "", "failed to create user")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("User provisioned", "user_id", user.ID)
+	w.Header().Set("Location", location(r, "/Users/"+strconv.Itoa(user.ID)))
+	writeJSON(w, r, http.StatusCreated, toUserResource(r, user))
+}
+
+// ReplaceUser handles PUT /Users/{id}
+func ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	user := lookupUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var res userResource
+	if !decode(w, r, &res) {
+		return
+	}
+	wasActive := user.Active
+	if err := res.apply(user); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	saveUser(w, r, user, wasActive)
+}
+
+// PatchUser handles PATCH /Users/{id}
+func PatchUser(w http.ResponseWriter, r *http.Request) {
+	user := lookupUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var patch patchRequest
+	if !decode(w, r, &patch) {
+		return
+	}
+	wasActive := user.Active
+	if err := patch.applyToUser(user); err != nil {
+		writeError(w, http.StatusBadRequest, patchErrorType(err), err.Error())
+		return
+	}
+	if err := validateUser(user); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	saveUser(w, r, user, wasActive)
+}
+
+// saveUser stores a replaced or patched user and answers with it
+func saveUser(w http.ResponseWriter, r *http.Request, user *repository.DirectoryUser, wasActive bool) {
+	if err := repository.UpdateDirectoryUser(r.Context(), user); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEmailTaken):
+			writeError(w, http.StatusConflict, "uniqueness", "a user with this userName already exists")
+		case errors.Is(err, repository.ErrUserNotFound):
+			writeError(w, http.StatusNotFound, "", "user not found")
+		case errors.Is(err, repository.ErrLastAdminDeactivation):
+			writeError(w, http.StatusConflict, "", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "", "failed to update user")
+		}
+		return
+	}
+
+	if user.Active != wasActive {
+		action := models.AuditUserReactivated
+		if !user.Active {
+			action = models.AuditUserDeactivated
+		}
+		err := repository.RecordAudit(r.Context(), &models.AuditEntry{
+			ActorID:   actorID(r),
+			SubjectID: &user.ID,
+			Action:    action,
+			Metadata:  map[string]interface{}{"source": "scim"},
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to record user activation change", "user_id", user.ID, "err", err)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, toUserResource(r, user))
+}
+
+// DeleteUser handles DELETE /Users/{id}. Most identity providers deactivate
+// users with PATCH instead, which keeps their data.
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	user := lookupUser(w, r)
+	if user == nil {
+		return
+	}
+	if err := repository.DeleteUser(r.Context(), user.ID, actorID(r), "scim"); err != nil {
+		if errors.Is(err, repository.ErrLastAdmin) {
+			writeError(w, http.StatusConflict, "", "the last administrator cannot be deleted")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "", "failed to delete user")
+		return
+	}
+	logging.FromContext(r.Context()).Info("User deprovisioned", "user_id", user.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GraphQLExtension is a gqlgen extension creating a span per operation and
+// per resolver
+type GraphQLExtension struct{}
+
+var (
+	_ graphql.HandlerExtension    = GraphQLExtension{}
+	_ graphql.ResponseInterceptor = GraphQLExtension{}
+	_ graphql.FieldInterceptor    = GraphQLExtension{}
+)
+
+// ExtensionName implements graphql.HandlerExtension
+func (GraphQLExtension) ExtensionName() string { return "Tracing" }
+
+// Validate implements graphql.HandlerExtension
+func (GraphQLExtension) Validate(graphql.ExecutableSchema) error { return nil }
+
+// InterceptResponse wraps each operation in a span
+func (GraphQLExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+
+	oc := graphql.GetOperationContext(ctx)
+	name, opType := oc.OperationName, "unknown"
+	if oc.Operation != nil {
+		opType = string(oc.Operation.Operation)
+	}
+	if name == "" {
+		name = "anonymous"
+	}
+
+	ctx, span := Tracer().Start(ctx, "graphql."+opType+" "+name, trace.WithAttributes(
+		attribute.String("graphql.operation.name", name),
+		attribute.String("graphql.operation.type", opType),
+	))
+	defer span.End()
+
+	resp := next(ctx)
+	if resp != nil && len(resp.Errors) > 0 {
+		span.SetStatus(codes.Error, resp.Errors.Error())
+	}
+	return resp
+}
+
+// InterceptField wraps fields backed by resolver code in a span
+func (GraphQLExtension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := Tracer().Start(ctx, fc.Object+"."+fc.Field.Name, trace.WithAttributes(
+		attribute.String("graphql.field.path", fc.Path().String()),
+	))
+	defer span.End()
+
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
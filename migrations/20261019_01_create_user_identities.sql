@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(32) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    linked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Every existing account has been reachable through email OTP, so record that
+-- as its first identity.
+INSERT INTO user_identities (user_id, provider, subject, email)
+SELECT id, 'email', LOWER(email), email FROM users
+ON CONFLICT (provider, subject) DO NOTHING;
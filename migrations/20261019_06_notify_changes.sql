@@ -0,0 +1,45 @@
+-- Publish user and session changes on the ums_events channel. NOTIFY is
+-- delivered on commit, so listeners never see changes that roll back, and
+-- changes made by any process (server replicas, umsctl) reach every replica.
+
+CREATE OR REPLACE FUNCTION notify_user_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('ums_events', json_build_object(
+            'type', 'user.deleted',
+            'user_id', OLD.id
+        )::text);
+        RETURN OLD;
+    END IF;
+
+    PERFORM pg_notify('ums_events', json_build_object(
+        'type', 'user.changed',
+        'user_id', NEW.id,
+        'user', json_build_object('id', NEW.id, 'name', NEW.name, 'email', NEW.email, 'role', NEW.role)
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_notify_change ON users;
+CREATE TRIGGER users_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION notify_user_change();
+
+CREATE OR REPLACE FUNCTION notify_session_revoked() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('ums_events', json_build_object(
+        'type', 'session.revoked',
+        'user_id', NEW.user_id,
+        'session_id', NEW.id
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS sessions_notify_revoked ON sessions;
+CREATE TRIGGER sessions_notify_revoked
+    AFTER UPDATE OF revoked_at ON sessions
+    FOR EACH ROW
+    WHEN (OLD.revoked_at IS NULL AND NEW.revoked_at IS NOT NULL)
+    EXECUTE FUNCTION notify_session_revoked();
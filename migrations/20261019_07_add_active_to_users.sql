@@ -0,0 +1,3 @@
+-- Deactivated users keep their account and data but cannot sign in. SCIM
+-- provisioning clients deactivate users instead of deleting them.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE;
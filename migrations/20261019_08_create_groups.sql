@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS groups (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_groups_name ON groups(LOWER(name));
+
+-- Roles granted to every member of a group, including members of its subgroups
+CREATE TABLE IF NOT EXISTS group_roles (
+    group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    PRIMARY KEY (group_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members(user_id);
+
+-- Nesting: members of the child group are members of the parent. The
+-- application keeps the graph acyclic.
+CREATE TABLE IF NOT EXISTS group_subgroups (
+    parent_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    child_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    PRIMARY KEY (parent_id, child_id),
+    CHECK (parent_id <> child_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_subgroups_child_id ON group_subgroups(child_id);
@@ -0,0 +1,41 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Every change bumps the version, so a writer holding an older version can
+-- tell that saving would overwrite someone else's change. Updates that
+-- change nothing keep it.
+CREATE OR REPLACE FUNCTION bump_user_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    NEW.updated_at := CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_bump_version ON users;
+CREATE TRIGGER users_bump_version
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+    WHEN (OLD.* IS DISTINCT FROM NEW.*)
+    EXECUTE FUNCTION bump_user_version();
+
+-- Include the version in change notifications
+CREATE OR REPLACE FUNCTION notify_user_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('ums_events', json_build_object(
+            'type', 'user.deleted',
+            'user_id', OLD.id
+        )::text);
+        RETURN OLD;
+    END IF;
+
+    PERFORM pg_notify('ums_events', json_build_object(
+        'type', 'user.changed',
+        'user_id', NEW.id,
+        'user', json_build_object('id', NEW.id, 'name', NEW.name, 'email', NEW.email, 'role', NEW.role,
+                                  'version', NEW.version, 'updated_at', NEW.updated_at)
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,7 @@
+-- An email change only takes effect once the new address is confirmed.
+-- Until then it is pending, with the hash of the token sent to it.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_change_token_hash TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_change_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_change_token_hash ON users(email_change_token_hash);
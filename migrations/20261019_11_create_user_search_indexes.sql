@@ -0,0 +1,17 @@
+-- Indexes behind user search (see internal/repository/search_repository.go).
+-- Queries must use the same expressions for the indexes to apply.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Typeahead: prefixes, in order. The C collation lets one index serve both
+-- the LIKE prefix and the ORDER BY.
+CREATE INDEX IF NOT EXISTS idx_users_lower_name_prefix ON users ((LOWER(name) COLLATE "C"));
+CREATE INDEX IF NOT EXISTS idx_users_lower_email_prefix ON users ((LOWER(email) COLLATE "C"));
+
+-- Substrings and misspellings
+CREATE INDEX IF NOT EXISTS idx_users_lower_name_trgm ON users USING GIN (LOWER(name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_lower_email_trgm ON users USING GIN (LOWER(email) gin_trgm_ops);
+
+-- Words in any order, name words ranking above email ones
+CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING GIN (
+    (setweight(to_tsvector('simple', name), 'A') || setweight(to_tsvector('simple', email), 'B'))
+);
@@ -0,0 +1,8 @@
+-- Users can ask for their account to be deleted. After a grace period, in
+-- which they can change their mind, their personal data is erased: the row
+-- is kept, anonymized, so audit entries still point at it.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
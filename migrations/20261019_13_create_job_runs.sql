@@ -0,0 +1,24 @@
+-- History of scheduled maintenance jobs. Every replica runs the scheduler;
+-- the unique slot makes sure only one of them runs a job for a given
+-- scheduled time.
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job VARCHAR(64) NOT NULL,
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- Host that ran the job
+    runner TEXT NOT NULL DEFAULT '',
+    -- RUNNING, SUCCEEDED or FAILED
+    status VARCHAR(16) NOT NULL DEFAULT 'RUNNING',
+    -- Rows the job removed or changed
+    affected BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (job, scheduled_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_started_at ON job_runs(started_at DESC);
+
+-- The purge jobs look these up by expiry
+CREATE INDEX IF NOT EXISTS idx_otps_expires_at ON otps(expires_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
@@ -0,0 +1,25 @@
+-- Publish group role, membership and nesting changes on the ums_events
+-- channel. Any of them may change users' effective roles; one notification
+-- per statement is enough, and NOTIFY folds identical ones in a transaction.
+
+CREATE OR REPLACE FUNCTION notify_group_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('ums_events', json_build_object('type', 'groups.changed')::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS group_roles_notify_change ON group_roles;
+CREATE TRIGGER group_roles_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON group_roles
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_group_change();
+
+DROP TRIGGER IF EXISTS group_members_notify_change ON group_members;
+CREATE TRIGGER group_members_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON group_members
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_group_change();
+
+DROP TRIGGER IF EXISTS group_subgroups_notify_change ON group_subgroups;
+CREATE TRIGGER group_subgroups_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON group_subgroups
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_group_change();
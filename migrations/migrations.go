@@ -0,0 +1,34 @@
+// Package migrations embeds the SQL migrations so the binaries can apply them
+// and check the schema version without the source tree.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Versions returns the migration versions (file names without .sql) in apply order
+func Versions() []string {
+	files, _ := fs.Glob(FS, "*.sql")
+	sort.Strings(files)
+
+	versions := make([]string, len(files))
+	for i, f := range files {
+		versions[i] = strings.TrimSuffix(f, ".sql")
+	}
+	return versions
+}
+
+// Latest returns the version the code expects the schema to be at
+func Latest() string {
+	versions := Versions()
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
@@ -7,8 +7,8 @@ export const REQUEST_OTP_MUTATION = gql`
 `;
 
 export const VERIFY_OTP_MUTATION = gql`
-  mutation VerifyOtp($email: String!, $otp: String!, $role: String) {
-    verifyOtp(email: $email, otp: $otp, role: $role) {
+  mutation VerifyOtp($email: String!, $otp: String!) {
+    verifyOtp(email: $email, otp: $otp) {
       token
       user {
         id
@@ -31,11 +31,12 @@ export const CREATE_USER_MUTATION = gql`
 `;
 
 export const UPDATE_USER_MUTATION = gql`
-  mutation UpdateUser($id: ID!, $name: String!, $email: String!) {
-    updateUser(id: $id, name: $name, email: $email) {
+  mutation UpdateUser($id: ID!, $input: UpdateUserInput!, $expectedVersion: Int) {
+    updateUser(id: $id, input: $input, expectedVersion: $expectedVersion) {
       id
       name
       email
+      version
     }
   }
 `;
@@ -45,3 +46,12 @@ export const DELETE_USER_MUTATION = gql`
     deleteUser(id: $id)
   }
 `;
+
+export const SET_USER_ROLE_MUTATION = gql`
+  mutation SetUserRole($id: ID!, $role: String!) {
+    setUserRole(id: $id, role: $role) {
+      id
+      role
+    }
+  }
+`;
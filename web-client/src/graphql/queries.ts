@@ -7,6 +7,28 @@ export const GET_USERS_QUERY = gql`
       name
       email
       role
+      version
+    }
+  }
+`;
+
+export const SEARCH_USERS_QUERY = gql`
+  query SearchUsers($query: String!) {
+    searchUsers(query: $query) {
+      user {
+        id
+        name
+        email
+        role
+        version
+      }
+      highlights {
+        field
+        matches {
+          start
+          end
+        }
+      }
     }
   }
 `;
@@ -1,7 +1,7 @@
 import React, { useState } from 'react';
 import { useQuery, useMutation } from 'urql';
-import { GET_USERS_QUERY } from '../graphql/queries';
-import { CREATE_USER_MUTATION, UPDATE_USER_MUTATION, DELETE_USER_MUTATION } from '../graphql/mutations';
+import { GET_USERS_QUERY, SEARCH_USERS_QUERY } from '../graphql/queries';
+import { CREATE_USER_MUTATION, UPDATE_USER_MUTATION, DELETE_USER_MUTATION, SET_USER_ROLE_MUTATION } from '../graphql/mutations';
 import { useAuth } from '../context/AuthContext';
 import { Layout } from '../components/Layout';
 import {
@@ -25,35 +25,59 @@ export const Dashboard: React.FC = () => {
     const [, createUser] = useMutation(CREATE_USER_MUTATION);
     const [, updateUser] = useMutation(UPDATE_USER_MUTATION);
     const [, deleteUser] = useMutation(DELETE_USER_MUTATION);
+    const [, setUserRole] = useMutation(SET_USER_ROLE_MUTATION);
 
     const [isModalOpen, setIsModalOpen] = useState(false);
     const [editingUser, setEditingUser] = useState<any>(null);
-    const [formData, setFormData] = useState({ name: '', email: '' });
+    const [formData, setFormData] = useState({ name: '', email: '', role: 'USER' });
+    const [formError, setFormError] = useState('');
     const [searchTerm, setSearchTerm] = useState('');
 
     const handleOpenModal = (user?: any) => {
         if (user) {
             setEditingUser(user);
-            setFormData({ name: user.name, email: user.email });
+            setFormData({ name: user.name, email: user.email, role: user.role || 'USER' });
         } else {
             setEditingUser(null);
-            setFormData({ name: '', email: '' });
+            setFormData({ name: '', email: '', role: 'USER' });
         }
+        setFormError('');
         setIsModalOpen(true);
     };
 
     const handleCloseModal = () => {
         setIsModalOpen(false);
         setEditingUser(null);
-        setFormData({ name: '', email: '' });
+        setFormData({ name: '', email: '', role: 'USER' });
+        setFormError('');
     };
 
     const handleSubmit = async (e: React.FormEvent) => {
         e.preventDefault();
+        const { role, ...details } = formData;
         if (editingUser) {
-            await updateUser({ id: editingUser.id, ...formData });
+            const updated = await updateUser({ id: editingUser.id, input: details, expectedVersion: editingUser.version });
+            if (updated.error) {
+                const conflict = updated.error.graphQLErrors.some((e) => e.extensions?.code === 'CONFLICT');
+                setFormError(conflict
+                    ? 'Someone else changed this teammate while you were editing. Reopen it to see their changes.'
+                    : updated.error.graphQLErrors[0]?.message || updated.error.message);
+                reexecuteQuery({ requestPolicy: 'network-only' });
+                return;
+            }
+            if (role !== (editingUser.role || 'USER')) {
+                const result = await setUserRole({ id: editingUser.id, role });
+                if (result.error) {
+                    setFormError(result.error.graphQLErrors[0]?.message || result.error.message);
+                    reexecuteQuery({ requestPolicy: 'network-only' });
+                    return;
+                }
+            }
+            if (details.email.trim() !== editingUser.email) {
+                window.alert(`The email changes once the code mailed to ${details.email.trim()} is confirmed.`);
+            }
         } else {
-            await createUser(formData);
+            await createUser(details);
         }
         handleCloseModal();
         reexecuteQuery({ requestPolicy: 'network-only' });
@@ -66,10 +90,28 @@ export const Dashboard: React.FC = () => {
         }
     };
 
-    const filteredUsers = (data?.users || []).filter((u: any) =>
-        u.name.toLowerCase().includes(searchTerm.toLowerCase()) ||
-        u.email.toLowerCase().includes(searchTerm.toLowerCase())
-    );
+    const query = searchTerm.trim();
+    const [searchResult] = useQuery({ query: SEARCH_USERS_QUERY, variables: { query }, pause: !query });
+    const filteredUsers = query
+        ? (searchResult.data?.searchUsers || []).map((r: any) => ({ ...r.user, highlights: r.highlights }))
+        : (data?.users || []);
+
+    // Marks the parts of a field that matched the search. Offsets count
+    // characters, as Array.from splits them.
+    const highlighted = (u: any, field: string) => {
+        const highlight = u.highlights?.find((h: any) => h.field === field);
+        if (!highlight) return u[field];
+        const chars = Array.from(u[field] as string);
+        const parts: React.ReactNode[] = [];
+        let pos = 0;
+        highlight.matches.forEach((m: any, i: number) => {
+            parts.push(chars.slice(pos, m.start).join(''));
+            parts.push(<mark key={i} className="bg-amber-100 text-inherit rounded">{chars.slice(m.start, m.end).join('')}</mark>);
+            pos = m.end;
+        });
+        parts.push(chars.slice(pos).join(''));
+        return parts;
+    };
 
     if (fetching) return (
         <div className="flex h-screen items-center justify-center bg-slate-50">
@@ -153,10 +195,10 @@ export const Dashboard: React.FC = () => {
                                                     {u.name.charAt(0)}
                                                 </div>
                                                 <div>
-                                                    <div className="font-bold text-slate-900">{u.name}</div>
+                                                    <div className="font-bold text-slate-900">{highlighted(u, 'name')}</div>
                                                     <div className="text-xs text-slate-500 flex items-center gap-1">
                                                         <Mail className="h-3 w-3" />
-                                                        {u.email}
+                                                        {highlighted(u, 'email')}
                                                     </div>
                                                 </div>
                                             </div>
@@ -248,6 +290,28 @@ export const Dashboard: React.FC = () => {
                                 />
                             </div>
 
+                            {editingUser && (
+                                <div>
+                                    <label className="label-modern">Role</label>
+                                    <select
+                                        value={formData.role}
+                                        onChange={(e) => setFormData({ ...formData, role: e.target.value })}
+                                        className="input-modern"
+                                    >
+                                        <option value="USER">User</option>
+                                        <option value="SUPPORT">Support</option>
+                                        <option value="ADMIN">Admin</option>
+                                    </select>
+                                </div>
+                            )}
+
+                            {formError && (
+                                <div className="bg-red-50 border border-red-100 text-red-700 p-4 rounded-xl text-sm flex items-center gap-3">
+                                    <ShieldAlert className="h-5 w-5 shrink-0 text-red-500" />
+                                    <span className="font-bold">{formError}</span>
+                                </div>
+                            )}
+
                             <div className="flex justify-end gap-3 pt-4">
                                 <button type="button" onClick={handleCloseModal} className="btn btn-ghost px-6">Cancel</button>
                                 <button type="submit" className="btn btn-primary px-8">